@@ -0,0 +1,9 @@
+// Command fnrun runs a function binary or .wasm module on a ResourceContext
+// built from separate files and writes its outputs as separate files.
+package main
+
+import "github.com/yndd/app-functions-sdk/go/fn/fnrun"
+
+func main() {
+	fnrun.Main(nil)
+}
@@ -0,0 +1,193 @@
+package fn
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// FingerprintAnnotation records on an output the fingerprint of the inputs it
+// was produced from, see Fingerprint.
+const FingerprintAnnotation = "app.yndd.io/fingerprint"
+
+// Fingerprint returns a content address of the inputs of rctx: the origin, the
+// target, the items, the functionConfig and the incoming outputs, together with
+// the name and version of the function. Inputs with the same canonical form
+// have the same fingerprint, see SubObject.Canonical.
+func Fingerprint(rctx *ResourceContext, name, version string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "function:%d:%s\n", len(name), name)
+	fmt.Fprintf(h, "version:%s\n", version)
+	write := func(section string, objs ...*KubeObject) {
+		for _, obj := range objs {
+			if obj == nil {
+				continue
+			}
+			b := obj.Canonical()
+			fmt.Fprintf(h, "%s:%d:", section, len(b))
+			h.Write(b)
+		}
+	}
+	if rctx.Input != nil {
+		write("origin", rctx.Input.Origin)
+		write("target", rctx.Input.Target)
+		write("items", rctx.Input.Items...)
+		write("functionConfig", rctx.Input.FunctionConfig)
+	}
+	write("outputs", rctx.Outputs...)
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Cache stores the outputs and Results of runs by the fingerprint of their
+// inputs. An entry is a ResourceContext in yaml. Implementations must be safe
+// for concurrent use.
+type Cache interface {
+	// Get returns the entry stored for key.
+	Get(key string) ([]byte, bool)
+	// Set stores entry for key.
+	Set(key string, entry []byte)
+}
+
+// Cached returns a processor that serves the runs of p from c. A run whose
+// inputs have the fingerprint of a previous successful run gets the inputs,
+// outputs and Results that run ended with, without calling p. The outputs of p
+// are annotated with the fingerprint, see FingerprintAnnotation. name and
+// version identify the function, so functions sharing c don't get the outputs
+// of each other, nor a new version of a function those of the old one. Runs
+// that fail are not cached.
+func Cached(p ContextProcessor, c Cache, name, version string) ContextProcessor {
+	return ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		key := Fingerprint(rctx, name, version)
+		if entry, ok := c.Get(key); ok {
+			cached, err := ParseResourceContext(entry)
+			if err == nil {
+				rctx.Input = cached.Input
+				rctx.Outputs = cached.Outputs
+				rctx.Results = append(rctx.Results, cached.Results...)
+				return true, nil
+			}
+			Logf("failed to read the cache entry %s, running the function: %v\n", key, err)
+		}
+
+		results := len(rctx.Results)
+		success, err := p.ProcessContext(ctx, rctx)
+		if err != nil || !success {
+			return success, err
+		}
+		for _, output := range rctx.Outputs {
+			output.SetAnnotation(FingerprintAnnotation, key)
+		}
+		// the entry is the ResourceContext the run ended with, including the
+		// changes p made to the inputs, with the Results of p only
+		entry, err := (&ResourceContext{
+			Input:   rctx.Input,
+			Outputs: rctx.Outputs,
+			Results: rctx.Results[results:],
+		}).ToYAML()
+		if err != nil {
+			Logf("failed to cache the run: %v\n", err)
+			return true, nil
+		}
+		c.Set(key, entry)
+		return true, nil
+	})
+}
+
+// NewLRUCache returns an in-memory Cache that holds the size most recently
+// used entries. A size of zero or less doesn't bound the cache.
+func NewLRUCache(size int) Cache {
+	return &lruCache{size: size, entries: map[string]*list.Element{}, order: list.New()}
+}
+
+type lruCache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+type lruEntry struct {
+	key   string
+	entry []byte
+}
+
+func (c *lruCache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(e)
+	return e.Value.(*lruEntry).entry, true
+}
+
+func (c *lruCache) Set(key string, entry []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[key]; ok {
+		e.Value.(*lruEntry).entry = entry
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(&lruEntry{key: key, entry: entry})
+	for c.order.Len() > c.size && c.size > 0 {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// NewDiskCache returns a Cache that stores every entry as a file in dir, so
+// the entries are shared by the runs of a function binary and survive it. The
+// directory is created if needed.
+func NewDiskCache(dir string) (Cache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &diskCache{dir: dir}, nil
+}
+
+type diskCache struct {
+	dir string
+}
+
+func (c *diskCache) file(key string) string {
+	return filepath.Join(c.dir, strings.ReplaceAll(key, ":", "-")+".yaml")
+}
+
+func (c *diskCache) Get(key string) ([]byte, bool) {
+	b, err := ioutil.ReadFile(c.file(key))
+	if err != nil {
+		return nil, false
+	}
+	return b, true
+}
+
+func (c *diskCache) Set(key string, entry []byte) {
+	// write to a temporary file first, so a concurrent Get never reads a
+	// partial entry
+	f, err := ioutil.TempFile(c.dir, ".entry-")
+	if err != nil {
+		Logf("failed to cache %s: %v\n", key, err)
+		return
+	}
+	_, err = f.Write(entry)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), c.file(key))
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		Logf("failed to cache %s: %v\n", key, err)
+	}
+}
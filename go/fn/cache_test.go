@@ -0,0 +1,181 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testCacheInput parses testInput.
+func testCacheInput(t *testing.T) *ResourceContext {
+	t.Helper()
+	rctx, err := ParseResourceContext([]byte(testInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rctx
+}
+
+func TestFingerprint(t *testing.T) {
+	key := Fingerprint(testCacheInput(t), "app-fn", "v1")
+	reordered, err := ParseResourceContext([]byte(`apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    metadata: {name: app1}  # same origin
+    kind: App
+    apiVersion: "app.yndd.io/v1alpha1"
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := Fingerprint(reordered, "app-fn", "v1"); got != key {
+		t.Errorf("expected the same fingerprint for the same canonical inputs, got %s and %s", key, got)
+	}
+
+	changed := testCacheInput(t)
+	changed.Input.Origin.SetLabel("a", "b")
+	for name, got := range map[string]string{
+		"name":    Fingerprint(testCacheInput(t), "other-fn", "v1"),
+		"version": Fingerprint(testCacheInput(t), "app-fn", "v2"),
+		"input":   Fingerprint(changed, "app-fn", "v1"),
+		"output": func() string {
+			rctx := testCacheInput(t)
+			rctx.Outputs = append(rctx.Outputs, testOutput(t))
+			return Fingerprint(rctx, "app-fn", "v1")
+		}(),
+		// the name and version are delimited
+		"split": Fingerprint(testCacheInput(t), "app-fnv", "1"),
+	} {
+		if got == key {
+			t.Errorf("expected a different fingerprint for a different %s", name)
+		}
+	}
+}
+
+func TestCached(t *testing.T) {
+	calls := 0
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		calls++
+		rctx.Input.Origin.SetLabel("rendered", "true")
+		rctx.Outputs = append(rctx.Outputs, testOutput(t))
+		rctx.LogResult(GeneralResult("rendered", Info))
+		return true, nil
+	})
+	c := NewLRUCache(0)
+	cached := Cached(p, c, "app-fn", "v1")
+
+	var runs []*ResourceContext
+	for i := 0; i < 2; i++ {
+		rctx := testCacheInput(t)
+		if _, err := cached.ProcessContext(context.Background(), rctx); err != nil {
+			t.Fatal(err)
+		}
+		runs = append(runs, rctx)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the second run to be served from the cache, got %d calls", calls)
+	}
+	key := Fingerprint(testCacheInput(t), "app-fn", "v1")
+	for i, rctx := range runs {
+		if got := rctx.Input.Origin.GetLabels()["rendered"]; got != "true" {
+			t.Errorf("run %d: expected the input change of the processor, got label %q", i, got)
+		}
+		if len(rctx.Outputs) != 1 || rctx.Outputs[0].GetAnnotation(FingerprintAnnotation) != key {
+			t.Errorf("run %d: expected the output annotated with %s, got %v", i, key, rctx.Outputs)
+		}
+		if len(rctx.Results) != 1 || rctx.Results[0].Message != "rendered" {
+			t.Errorf("run %d: expected the result of the processor, got %v", i, rctx.Results)
+		}
+	}
+
+	if _, err := Cached(p, c, "other-fn", "v1").ProcessContext(context.Background(), testCacheInput(t)); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Errorf("expected another function not to get the cached run, got %d calls", calls)
+	}
+}
+
+func TestCachedFailure(t *testing.T) {
+	calls := 0
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		calls++
+		rctx.Outputs = append(rctx.Outputs, testOutput(t))
+		return false, errors.New("failed")
+	})
+	cached := Cached(p, NewLRUCache(0), "app-fn", "v1")
+	for i := 0; i < 2; i++ {
+		if _, err := cached.ProcessContext(context.Background(), testCacheInput(t)); err == nil {
+			t.Fatal("expected the error of the processor")
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected a failed run not to be cached, got %d calls", calls)
+	}
+}
+
+func TestLRUCache(t *testing.T) {
+	c := NewLRUCache(2)
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	c.Set("a", []byte("1"))
+	c.Set("b", []byte("2"))
+	// a becomes the most recently used entry, so b is evicted by c
+	if entry, ok := c.Get("a"); !ok || string(entry) != "1" {
+		t.Fatalf("expected a hit for a, got %q, %v", entry, ok)
+	}
+	c.Set("c", []byte("3"))
+	if _, ok := c.Get("b"); ok {
+		t.Error("expected b to be evicted")
+	}
+	c.Set("a", []byte("4"))
+	for key, want := range map[string]string{"a": "4", "c": "3"} {
+		if entry, ok := c.Get(key); !ok || string(entry) != want {
+			t.Errorf("expected %q for %s, got %q, %v", want, key, entry, ok)
+		}
+	}
+
+	unbounded := NewLRUCache(0)
+	for _, key := range []string{"a", "b", "c"} {
+		unbounded.Set(key, []byte(key))
+	}
+	for _, key := range []string{"a", "b", "c"} {
+		if _, ok := unbounded.Get(key); !ok {
+			t.Errorf("expected an unbounded cache to keep %s", key)
+		}
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := Fingerprint(testCacheInput(t), "app-fn", "v1")
+	if _, ok := c.Get(key); ok {
+		t.Fatal("expected a miss on an empty cache")
+	}
+	c.Set(key, []byte("1"))
+	c.Set(key, []byte("2"))
+
+	// the entries survive the cache
+	reopened, err := NewDiskCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if entry, ok := reopened.Get(key); !ok || string(entry) != "2" {
+		t.Errorf("expected the last entry, got %q, %v", entry, ok)
+	}
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || filepath.Ext(files[0].Name()) != ".yaml" {
+		t.Errorf("expected a single entry file without temporary files, got %v", files)
+	}
+}
@@ -0,0 +1,138 @@
+package fn
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Canonical returns the content of the object as compact json that only
+// depends on its values: keys are sorted, comments and quoting styles are left
+// out and numbers are written in one form, so 1000, 1e3, 0x3E8 and 1000.0 are
+// the same. Floats that are not finite are written as the strings "+Inf",
+// "-Inf" and "NaN".
+func (o *SubObject) Canonical() []byte {
+	var b bytes.Buffer
+	writeCanonical(&b, o.obj.Node())
+	return b.Bytes()
+}
+
+// Hash returns the sha256 of Canonical as "sha256:<hex>", so objects with the
+// same values have the same hash.
+func (o *SubObject) Hash() string {
+	sum := sha256.Sum256(o.Canonical())
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func writeCanonical(b *bytes.Buffer, node *yaml.Node) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			b.WriteString("null")
+			return
+		}
+		writeCanonical(b, node.Content[0])
+	case yaml.AliasNode:
+		writeCanonical(b, node.Alias)
+	case yaml.MappingNode:
+		fields := map[string]*yaml.Node{}
+		keys := make([]string, 0, len(node.Content)/2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			k := node.Content[i].Value
+			if _, ok := fields[k]; !ok {
+				keys = append(keys, k)
+			}
+			fields[k] = node.Content[i+1]
+		}
+		sort.Strings(keys)
+		b.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeString(b, k)
+			b.WriteByte(':')
+			writeCanonical(b, fields[k])
+		}
+		b.WriteByte('}')
+	case yaml.SequenceNode:
+		b.WriteByte('[')
+		for i, n := range node.Content {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeCanonical(b, n)
+		}
+		b.WriteByte(']')
+	default:
+		writeScalar(b, node)
+	}
+}
+
+func writeScalar(b *bytes.Buffer, node *yaml.Node) {
+	switch node.ShortTag() {
+	case yaml.NodeTagNull:
+		b.WriteString("null")
+		return
+	case yaml.NodeTagBool:
+		var v bool
+		if err := node.Decode(&v); err == nil {
+			b.WriteString(strconv.FormatBool(v))
+			return
+		}
+	case yaml.NodeTagInt:
+		if i, ok := decodeInt(node); ok {
+			b.WriteString(i.String())
+			return
+		}
+	case yaml.NodeTagFloat:
+		var f float64
+		if err := node.Decode(&f); err == nil {
+			writeFloat(b, f)
+			return
+		}
+	}
+	writeString(b, node.Value)
+}
+
+// decodeInt decodes an integer the way yaml does, without the bounds of int64.
+func decodeInt(node *yaml.Node) (*big.Int, bool) {
+	var i int64
+	if err := node.Decode(&i); err == nil {
+		return big.NewInt(i), true
+	}
+	var u uint64
+	if err := node.Decode(&u); err == nil {
+		return new(big.Int).SetUint64(u), true
+	}
+	return new(big.Int).SetString(node.Value, 0)
+}
+
+func writeFloat(b *bytes.Buffer, f float64) {
+	switch {
+	case math.IsInf(f, 1):
+		writeString(b, "+Inf")
+	case math.IsInf(f, -1):
+		writeString(b, "-Inf")
+	case math.IsNaN(f):
+		writeString(b, "NaN")
+	case f == math.Trunc(f) && math.Abs(f) < 1<<53:
+		// integral floats are written as the integer they equal
+		b.WriteString(strconv.FormatInt(int64(f), 10))
+	default:
+		b.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	}
+}
+
+func writeString(b *bytes.Buffer, s string) {
+	// marshaling a string can't fail
+	q, _ := json.Marshal(s)
+	b.Write(q)
+}
@@ -0,0 +1,103 @@
+package fn
+
+import (
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	const want = `{"data":{"count":1000,"enabled":true,"name":"a","ratio":0.5,"tags":["x","y"]},"kind":"ConfigMap"}`
+	tests := map[string]string{
+		"block": `kind: ConfigMap
+data:
+  name: a
+  count: 1000
+  enabled: true
+  ratio: 0.5
+  tags:
+  - x
+  - y
+`,
+		"key order": `data:
+  tags: [x, y]
+  ratio: 0.5
+  enabled: true
+  count: 1000
+  name: a
+kind: ConfigMap
+`,
+		"scalar styles": `kind: 'ConfigMap'
+data: {name: "a", count: 1e3, enabled: True, ratio: .5, tags: ["x", 'y']}
+`,
+		"number forms": `kind: ConfigMap
+data: {name: a, count: 0x3E8, enabled: true, ratio: 5e-1, tags: [x, y]}
+`,
+		"comments": `# head comment
+kind: ConfigMap # line comment
+data:
+  # field comment
+  name: a
+  count: 1000.0
+  enabled: true
+  ratio: 0.5
+  tags:
+  - x # item comment
+  - y
+# foot comment
+`,
+		"anchor": `kind: ConfigMap
+data:
+  name: &name a
+  count: 1000
+  enabled: true
+  ratio: 0.5
+  tags: [x, y]
+`,
+	}
+	var hash string
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj, err := ParseKubeObject([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := string(obj.Canonical()); got != want {
+				t.Errorf("expected\n%s\ngot\n%s", want, got)
+			}
+			if hash == "" {
+				hash = obj.Hash()
+			} else if obj.Hash() != hash {
+				t.Errorf("expected hash %s, got %s", hash, obj.Hash())
+			}
+		})
+	}
+}
+
+func TestHashDiffers(t *testing.T) {
+	base := "kind: ConfigMap\ndata: {name: a, count: 1000, tags: [x, y]}\n"
+	tests := map[string]string{
+		"value":        "kind: ConfigMap\ndata: {name: b, count: 1000, tags: [x, y]}\n",
+		"number":       "kind: ConfigMap\ndata: {name: a, count: 1001, tags: [x, y]}\n",
+		"quoted int":   "kind: ConfigMap\ndata: {name: a, count: \"1000\", tags: [x, y]}\n",
+		"list order":   "kind: ConfigMap\ndata: {name: a, count: 1000, tags: [y, x]}\n",
+		"missing key":  "kind: ConfigMap\ndata: {name: a, count: 1000}\n",
+		"extra key":    "kind: ConfigMap\ndata: {name: a, count: 1000, tags: [x, y], other: c}\n",
+		"null":         "kind: ConfigMap\ndata: {name: a, count: null, tags: [x, y]}\n",
+		"empty string": "kind: ConfigMap\ndata: {name: '', count: 1000, tags: [x, y]}\n",
+	}
+	obj, err := ParseKubeObject([]byte(base))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := obj.Hash()
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			other, err := ParseKubeObject([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if other.Hash() == hash {
+				t.Errorf("expected a different hash than %s for\n%s", base, other.Canonical())
+			}
+		})
+	}
+}
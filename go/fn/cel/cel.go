@@ -0,0 +1,317 @@
+// Package cel evaluates CEL expressions against the objects of a
+// ResourceContext, so invariants of the inputs and outputs of a function can be
+// stated declaratively rather than in Go.
+//
+// A rule is evaluated for every object in its scopes. The object is exposed as
+// the variable `object`; the origin, the target, the items and the outputs of
+// the ResourceContext are exposed as `origin`, `target`, `items` and `outputs`.
+// Objects are plain CEL maps, e.g.
+//
+//	object.spec.mtu <= 9216
+//	!has(origin.metadata.labels) || origin.metadata.labels.all(k,
+//	    has(object.metadata.labels) && k in object.metadata.labels &&
+//	    object.metadata.labels[k] == origin.metadata.labels[k])
+package cel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/ext"
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// Scope selects the objects of a ResourceContext a Rule is evaluated for.
+type Scope string
+
+const (
+	ScopeOrigin  Scope = "origin"
+	ScopeTarget  Scope = "target"
+	ScopeItems   Scope = "items"
+	ScopeOutputs Scope = "outputs"
+)
+
+const (
+	varObject  = "object"
+	varOrigin  = "origin"
+	varTarget  = "target"
+	varItems   = "items"
+	varOutputs = "outputs"
+
+	// DefaultCostLimit bounds the cost of evaluating a rule for one object.
+	DefaultCostLimit = 1000000
+)
+
+// Rule is a CEL expression that must evaluate to true for every object in its
+// scopes.
+type Rule struct {
+	// Name identifies the rule in the Results.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Expression is the CEL expression. It must evaluate to a bool.
+	Expression string `yaml:"expression" json:"expression"`
+	// Message is reported when the expression evaluates to false. It defaults to
+	// the expression.
+	Message string `yaml:"message,omitempty" json:"message,omitempty"`
+	// Severity of the Result reported when the expression evaluates to false.
+	// It defaults to error.
+	Severity fn.Severity `yaml:"severity,omitempty" json:"severity,omitempty"`
+	// Scopes are the objects the rule is evaluated for. It defaults to the
+	// outputs.
+	Scopes []Scope `yaml:"scopes,omitempty" json:"scopes,omitempty"`
+	// Match restricts the rule to objects of these kinds.
+	Match fn.GroupVersionKinds `yaml:"match,omitempty" json:"match,omitempty"`
+}
+
+// Rules is a list of Rules, e.g. as found in a functionConfig.
+type Rules struct {
+	Rules []Rule `yaml:"rules,omitempty" json:"rules,omitempty"`
+}
+
+// RulesFromFunctionConfig returns the rules of the functionConfig of rctx: the
+// `rules` field, or the `rules` key holding a yaml list for a ConfigMap. It
+// returns no rules if there is no functionConfig.
+func RulesFromFunctionConfig(rctx *fn.ResourceContext) ([]Rule, error) {
+	if rctx.Input == nil || rctx.Input.FunctionConfig == nil {
+		return nil, nil
+	}
+	fnConfig := rctx.Input.FunctionConfig
+	if fnConfig.IsGVK("", "v1", "ConfigMap") {
+		data, err := rctx.GetFunctionConfigData()
+		if err != nil || data["rules"] == "" {
+			return nil, err
+		}
+		obj, err := fn.ParseKubeObject([]byte("rules:\n" + indent(data["rules"])))
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the rules of the functionConfig: %w", err)
+		}
+		fnConfig = obj
+	}
+	rules := &Rules{}
+	if err := fnConfig.As(rules); err != nil {
+		return nil, fmt.Errorf("unable to parse the rules of the functionConfig: %w", err)
+	}
+	return rules.Rules, nil
+}
+
+// Evaluator evaluates compiled Rules against ResourceContexts.
+type Evaluator struct {
+	rules     []*compiledRule
+	costLimit uint64
+}
+
+type compiledRule struct {
+	Rule
+	program cel.Program
+}
+
+// NewEvaluator compiles rules. It returns an error naming the rules that don't
+// compile or don't evaluate to a bool.
+func NewEvaluator(rules ...Rule) (*Evaluator, error) {
+	env, err := cel.NewEnv(
+		cel.Variable(varObject, cel.DynType),
+		cel.Variable(varOrigin, cel.DynType),
+		cel.Variable(varTarget, cel.DynType),
+		cel.Variable(varItems, cel.ListType(cel.DynType)),
+		cel.Variable(varOutputs, cel.ListType(cel.DynType)),
+		ext.Strings(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	e := &Evaluator{costLimit: DefaultCostLimit}
+	var errs []string
+	for i, rule := range rules {
+		if rule.Name == "" {
+			rule.Name = fmt.Sprintf("rule[%d]", i)
+		}
+		ast, issues := env.Compile(rule.Expression)
+		if issues != nil && issues.Err() != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", rule.Name, issues.Err()))
+			continue
+		}
+		if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+			errs = append(errs, fmt.Sprintf("%s: must evaluate to bool, not %s", rule.Name, ast.OutputType()))
+			continue
+		}
+		program, err := env.Program(ast, cel.CostLimit(e.costLimit), cel.InterruptCheckFrequency(100))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", rule.Name, err))
+			continue
+		}
+		e.rules = append(e.rules, &compiledRule{Rule: rule, program: program})
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid CEL rules:\n%s", strings.Join(errs, "\n"))
+	}
+	return e, nil
+}
+
+// Evaluate evaluates the rules for the objects in the given scopes, or in all
+// scopes if none are given, and returns a Result for every object a rule
+// doesn't hold for. An expression that fails to evaluate, e.g. because a field
+// is missing, is reported with the severity of its rule.
+func (e *Evaluator) Evaluate(ctx context.Context, rctx *fn.ResourceContext, scopes ...Scope) fn.Results {
+	vars := map[string]interface{}{
+		varOrigin:  nil,
+		varTarget:  nil,
+		varItems:   []interface{}{},
+		varOutputs: toValues(rctx.Outputs),
+	}
+	if rctx.Input != nil {
+		vars[varOrigin] = toValue(rctx.Input.Origin)
+		vars[varTarget] = toValue(rctx.Input.Target)
+		vars[varItems] = toValues(rctx.Input.Items)
+	}
+
+	var results fn.Results
+	for _, rule := range e.rules {
+		for _, scope := range rule.scopes() {
+			if len(scopes) > 0 && !hasScope(scopes, scope) {
+				continue
+			}
+			for _, obj := range objectsIn(rctx, scope) {
+				if len(rule.Match) > 0 && !rule.Match.Matches(obj) {
+					continue
+				}
+				if result := rule.evaluate(ctx, obj, vars); result != nil {
+					results = append(results, result)
+				}
+			}
+		}
+	}
+	return results
+}
+
+// Processor returns a processor that evaluates the rules for the origin, the
+// target and the items before it calls p, and for the outputs after p returns.
+// Results of error severity fail the run.
+func (e *Evaluator) Processor(p fn.ContextProcessor) fn.ContextProcessor {
+	return fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		if err := e.logResults(rctx, e.Evaluate(ctx, rctx, ScopeOrigin, ScopeTarget, ScopeItems)); err != nil {
+			return false, err
+		}
+		success, err := p.ProcessContext(ctx, rctx)
+		if err != nil || !success {
+			return success, err
+		}
+		if err := e.logResults(rctx, e.Evaluate(ctx, rctx, ScopeOutputs)); err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+// logResults logs results and returns the errors among them.
+func (e *Evaluator) logResults(rctx *fn.ResourceContext, results fn.Results) error {
+	var errs fn.Results
+	for _, result := range results {
+		rctx.LogResult(result)
+		if result.Severity == fn.Error {
+			errs = append(errs, result)
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (r *compiledRule) scopes() []Scope {
+	if len(r.Scopes) == 0 {
+		return []Scope{ScopeOutputs}
+	}
+	return r.Scopes
+}
+
+func (r *compiledRule) severity() fn.Severity {
+	if r.Severity == "" {
+		return fn.Error
+	}
+	return r.Severity
+}
+
+// evaluate evaluates the rule for obj and returns a Result if it doesn't hold.
+func (r *compiledRule) evaluate(ctx context.Context, obj *fn.KubeObject, vars map[string]interface{}) *fn.Result {
+	activation := map[string]interface{}{varObject: toValue(obj)}
+	for k, v := range vars {
+		activation[k] = v
+	}
+	out, _, err := r.program.ContextEval(ctx, activation)
+	if err != nil {
+		return fn.ConfigObjectResult(fmt.Sprintf("%s: failed to evaluate %q: %v", r.Name, r.Expression, err), obj, r.severity())
+	}
+	holds, ok := out.Value().(bool)
+	if !ok {
+		return fn.ConfigObjectResult(fmt.Sprintf("%s: %q evaluated to %v, not a bool", r.Name, r.Expression, out.Value()), obj, r.severity())
+	}
+	if holds {
+		return nil
+	}
+	msg := r.Message
+	if msg == "" {
+		msg = fmt.Sprintf("failed rule %q", r.Expression)
+	}
+	return fn.ConfigObjectResult(fmt.Sprintf("%s: %s", r.Name, msg), obj, r.severity())
+}
+
+func objectsIn(rctx *fn.ResourceContext, scope Scope) fn.KubeObjects {
+	switch scope {
+	case ScopeOutputs:
+		return rctx.Outputs
+	}
+	if rctx.Input == nil {
+		return nil
+	}
+	switch scope {
+	case ScopeOrigin:
+		if rctx.Input.Origin != nil {
+			return fn.KubeObjects{rctx.Input.Origin}
+		}
+	case ScopeTarget:
+		if rctx.Input.Target != nil {
+			return fn.KubeObjects{rctx.Input.Target}
+		}
+	case ScopeItems:
+		return rctx.Input.Items
+	}
+	return nil
+}
+
+// toValue converts obj to a plain map, which CEL exposes as a map.
+func toValue(obj *fn.KubeObject) interface{} {
+	if obj == nil {
+		return nil
+	}
+	var v map[string]interface{}
+	if err := obj.As(&v); err != nil {
+		return nil
+	}
+	return v
+}
+
+func toValues(objs fn.KubeObjects) []interface{} {
+	values := []interface{}{}
+	for _, obj := range objs {
+		values = append(values, toValue(obj))
+	}
+	return values
+}
+
+func hasScope(scopes []Scope, scope Scope) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func indent(s string) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	for i := range lines {
+		lines[i] = "  " + lines[i]
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
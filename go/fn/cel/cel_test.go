@@ -0,0 +1,197 @@
+package cel
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// testContext returns a ResourceContext with an origin labeled app=a and the
+// given outputs.
+func testContext(t *testing.T, outputs ...string) *fn.ResourceContext {
+	t.Helper()
+	parse := func(in string) *fn.KubeObject {
+		obj, err := fn.ParseKubeObject([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	rctx := &fn.ResourceContext{Input: &fn.ResourceContextInputs{
+		Origin: parse("apiVersion: app.yndd.io/v1alpha1\nkind: App\nmetadata:\n  name: app1\n  labels:\n    app: a\nspec:\n  mtu: 1500\n"),
+	}}
+	for _, output := range outputs {
+		rctx.Outputs = append(rctx.Outputs, parse(output))
+	}
+	return rctx
+}
+
+func TestNewEvaluatorErrors(t *testing.T) {
+	tests := map[string]struct {
+		rules []Rule
+		want  []string
+	}{
+		"syntax": {
+			rules: []Rule{{Name: "broken", Expression: "object.spec.mtu <="}},
+			want:  []string{"broken: ERROR: <input>:1:19: Syntax error"},
+		},
+		"undeclared": {
+			rules: []Rule{{Expression: "node.spec.mtu > 0"}},
+			want:  []string{"rule[0]: ERROR: <input>:1:1: undeclared reference to 'node'"},
+		},
+		"not a bool": {
+			rules: []Rule{{Name: "valid", Expression: "true"}, {Name: "count", Expression: "size(outputs)"}},
+			want:  []string{"count: must evaluate to bool, not int"},
+		},
+		"several": {
+			rules: []Rule{{Expression: "1 +"}, {Expression: "'a'"}},
+			want:  []string{"rule[0]: ", "rule[1]: must evaluate to bool, not string"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := NewEvaluator(tc.rules...)
+			if err == nil || e != nil {
+				t.Fatalf("expected an error, got %v", err)
+			}
+			if !strings.HasPrefix(err.Error(), "invalid CEL rules:\n") {
+				t.Fatalf("unexpected error %q", err.Error())
+			}
+			for _, want := range tc.want {
+				if !strings.Contains(err.Error(), "\n"+want) {
+					t.Errorf("expected the error to name %q, got\n%s", want, err.Error())
+				}
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	outputs := []string{
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: labeled\n  labels:\n    app: a\n",
+		"apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: unlabeled\n",
+		"apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\n",
+	}
+	tests := map[string]struct {
+		rule Rule
+		want []string
+	}{
+		"holds": {
+			rule: Rule{Name: "named", Expression: "object.metadata.name != ''"},
+		},
+		"failed": {
+			rule: Rule{Name: "labels", Expression: "has(object.metadata.labels) && object.metadata.labels.app == origin.metadata.labels.app"},
+			want: []string{
+				`error ConfigMap/unlabeled: labels: failed rule "has(object.metadata.labels) && object.metadata.labels.app == origin.metadata.labels.app"`,
+				`error Secret/s: labels: failed rule "has(object.metadata.labels) && object.metadata.labels.app == origin.metadata.labels.app"`,
+			},
+		},
+		"message and severity": {
+			rule: Rule{Name: "labels", Expression: "has(object.metadata.labels)", Message: "must be labeled", Severity: fn.Warning},
+			want: []string{
+				"warning ConfigMap/unlabeled: labels: must be labeled",
+				"warning Secret/s: labels: must be labeled",
+			},
+		},
+		"match": {
+			rule: Rule{Name: "labels", Expression: "has(object.metadata.labels)", Match: fn.GroupVersionKinds{{Version: "v1", Kind: "Secret"}}},
+			want: []string{`error Secret/s: labels: failed rule "has(object.metadata.labels)"`},
+		},
+		"scopes": {
+			rule: Rule{Name: "mtu", Expression: "object.spec.mtu <= 1400", Scopes: []Scope{ScopeOrigin}},
+			want: []string{`error App/app1: mtu: failed rule "object.spec.mtu <= 1400"`},
+		},
+		"evaluation error": {
+			rule: Rule{Name: "mtu", Expression: "object.spec.mtu > 0", Match: fn.GroupVersionKinds{{Version: "v1", Kind: "Secret"}}, Severity: fn.Warning},
+			want: []string{`warning Secret/s: mtu: failed to evaluate "object.spec.mtu > 0": no such key: spec`},
+		},
+		"dyn not a bool": {
+			rule: Rule{Name: "dyn", Expression: "object.metadata.name", Scopes: []Scope{ScopeOrigin}},
+			want: []string{`error App/app1: dyn: "object.metadata.name" evaluated to app1, not a bool`},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			e, err := NewEvaluator(tc.rule)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range e.Evaluate(context.Background(), testContext(t, outputs...)) {
+				ref := result.ResourceRef
+				got = append(got, string(result.Severity)+" "+ref.Kind+"/"+ref.Name+": "+result.Message)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected\n%q\ngot\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	e, err := NewEvaluator(
+		Rule{Name: "mtu", Expression: "object.spec.mtu >= 1500", Scopes: []Scope{ScopeOrigin}},
+		Rule{Name: "labels", Expression: "has(object.metadata.labels)", Severity: fn.Warning},
+		Rule{Name: "named", Expression: "object.metadata.name != 'forbidden'"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		output  string
+		success bool
+		results int
+	}{
+		"warning": {output: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n", success: true, results: 1},
+		"error":   {output: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: forbidden\n  labels: {a: b}\n", results: 1},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+				output, err := fn.ParseKubeObject([]byte(tc.output))
+				rctx.Outputs = append(rctx.Outputs, output)
+				return err == nil, err
+			})
+			rctx := testContext(t)
+			success, err := e.Processor(p).ProcessContext(context.Background(), rctx)
+			if success != tc.success || len(rctx.Results) != tc.results {
+				t.Fatalf("expected success %v with %d results, got %v, %v", tc.success, tc.results, success, rctx.Results)
+			}
+			var results fn.Results
+			if !tc.success && (!errors.As(err, &results) || len(results) != 1) {
+				t.Errorf("expected the error Results as error, got %v", err)
+			}
+			if tc.success && err != nil {
+				t.Errorf("expected a warning not to fail the run, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRulesFromFunctionConfig(t *testing.T) {
+	want := []Rule{{Name: "mtu", Expression: "object.spec.mtu > 0", Scopes: []Scope{ScopeOrigin}}}
+	for name, config := range map[string]string{
+		"typed":     "apiVersion: fn.yndd.io/v1\nkind: Rules\nmetadata:\n  name: rules\nrules:\n- name: mtu\n  expression: object.spec.mtu > 0\n  scopes: [origin]\n",
+		"ConfigMap": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: rules\ndata:\n  rules: |\n    - name: mtu\n      expression: object.spec.mtu > 0\n      scopes: [origin]\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			rctx := testContext(t)
+			obj, err := fn.ParseKubeObject([]byte(config))
+			if err != nil {
+				t.Fatal(err)
+			}
+			rctx.Input.FunctionConfig = obj
+			rules, err := RulesFromFunctionConfig(rctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(rules, want) {
+				t.Errorf("expected %+v, got %+v", want, rules)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+// Package client invokes function executables, so orchestrators and test
+// harnesses share a single way to run a function on a ResourceContext.
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+)
+
+const (
+	// DefaultTimeout bounds a function run when no timeout is configured.
+	DefaultTimeout = 5 * time.Minute
+
+	// DefaultMaxOutputSize bounds the ResourceContext a function returns when
+	// no limit is configured.
+	DefaultMaxOutputSize = 64 << 20
+
+	// DefaultMaxStderrSize bounds the logs captured from stderr; the logs beyond
+	// it are dropped.
+	DefaultMaxStderrSize = 1 << 20
+)
+
+// waitDelay bounds the wait for the stdout and stderr of a function once it
+// exited or was killed.
+const waitDelay = 5 * time.Second
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithArgs passes args to the function executable.
+func WithArgs(args ...string) Option {
+	return func(c *Client) {
+		c.args = append(c.args, args...)
+	}
+}
+
+// WithEnv adds variables to the environment of the function executable, in the
+// form "key=value". The function inherits the environment of the current
+// process; a variable set by WithEnv overrides the inherited one.
+func WithEnv(env ...string) Option {
+	return func(c *Client) {
+		c.env = append(c.env, env...)
+	}
+}
+
+// WithTimeout bounds the duration of a function run. A timeout of 0 disables
+// it.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.timeout = d
+	}
+}
+
+// WithMaxOutputSize bounds the size of the ResourceContext the function writes
+// to stdout. The function is killed when it writes more.
+func WithMaxOutputSize(n int64) Option {
+	return func(c *Client) {
+		c.maxOutputSize = n
+	}
+}
+
+// WithStderr copies the logs the function writes to stderr to w, in addition
+// to capturing them in the Response.
+func WithStderr(w io.Writer) Option {
+	return func(c *Client) {
+		c.stderr = w
+	}
+}
+
+// Client runs a function executable with a ResourceContext on stdin.
+type Client struct {
+	path          string
+	args          []string
+	env           []string
+	timeout       time.Duration
+	maxOutputSize int64
+	stderr        io.Writer
+}
+
+// New returns a Client for the function executable at path.
+func New(path string, opts ...Option) *Client {
+	c := &Client{
+		path:          path,
+		timeout:       DefaultTimeout,
+		maxOutputSize: DefaultMaxOutputSize,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Response is the outcome of a function run.
+type Response struct {
+	// ResourceContext is the ResourceContext the function returned, if it
+	// returned one.
+	ResourceContext *fn.ResourceContext
+	// Raw is the output of the function.
+	Raw []byte
+	// Stderr holds the logs of the function.
+	Stderr []byte
+	// ExitCode is the exit code of the function.
+	ExitCode int
+	// Duration is the duration of the run.
+	Duration time.Duration
+}
+
+// Results returns the Results of the returned ResourceContext.
+func (r *Response) Results() fn.Results {
+	if r == nil || r.ResourceContext == nil {
+		return nil
+	}
+	return r.ResourceContext.Results
+}
+
+// Run runs the function on rctx.
+func (c *Client) Run(ctx context.Context, rctx *fn.ResourceContext) (*Response, error) {
+	in, err := rctx.ToYAML()
+	if err != nil {
+		return nil, err
+	}
+	return c.RunBytes(ctx, in)
+}
+
+// RunBytes runs the function on the ResourceContext in yaml in. The Response
+// is returned whenever the function ran, along with an *ExitError,
+// *TimeoutError, *OutputLimitError or *ParseError if it failed.
+func (c *Client) RunBytes(ctx context.Context, in []byte) (*Response, error) {
+	var cancel context.CancelFunc
+	if c.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	stdout := &internal.LimitWriter{Limit: c.maxOutputSize, Exceeded: cancel}
+	stderr := &internal.LimitWriter{Limit: DefaultMaxStderrSize}
+	cmd := exec.CommandContext(ctx, c.path, c.args...)
+	cmd.Stdin = bytes.NewReader(in)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if c.stderr != nil {
+		cmd.Stderr = io.MultiWriter(stderr, c.stderr)
+	}
+	if c.env != nil {
+		cmd.Env = append(os.Environ(), c.env...)
+	}
+	// the function is killed with the processes it started, and Wait doesn't
+	// wait long for the ones that escaped and keep stdout or stderr open
+	killProcessGroup(cmd)
+	cmd.WaitDelay = waitDelay
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("unable to start function %s: %w", c.path, err)
+	}
+	waitErr := cmd.Wait()
+	resp := &Response{
+		Raw:      stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+		Duration: time.Since(start),
+	}
+
+	switch {
+	case stdout.Overflow():
+		return resp, &OutputLimitError{Path: c.path, Limit: c.maxOutputSize}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return resp, &TimeoutError{Path: c.path, Timeout: c.timeout}
+	case ctx.Err() != nil:
+		return resp, ctx.Err()
+	}
+	if len(bytes.TrimSpace(resp.Raw)) > 0 {
+		rctx, err := fn.ParseResourceContext(resp.Raw)
+		if err != nil {
+			return resp, &ParseError{Path: c.path, Err: err}
+		}
+		resp.ResourceContext = rctx
+	}
+	var exitErr *exec.ExitError
+	if errors.As(waitErr, &exitErr) {
+		return resp, &ExitError{
+			Path:     c.path,
+			ExitCode: resp.ExitCode,
+			Stderr:   string(resp.Stderr),
+			Results:  resp.Results(),
+		}
+	}
+	if waitErr != nil {
+		return resp, fmt.Errorf("function %s failed: %w", c.path, waitErr)
+	}
+	if resp.ResourceContext == nil {
+		return resp, &ParseError{Path: c.path, Err: fmt.Errorf("the function returned no ResourceContext")}
+	}
+	return resp, nil
+}
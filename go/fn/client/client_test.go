@@ -0,0 +1,231 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+const testInput = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+`
+
+// helperEnv selects the behavior of TestHelperProcess.
+const helperEnv = "FN_CLIENT_HELPER"
+
+// newHelperClient returns a Client that runs the test binary as a function
+// behaving as mode, see TestHelperProcess.
+func newHelperClient(mode string, opts ...Option) *Client {
+	opts = append([]Option{
+		WithArgs("-test.run=^TestHelperProcess$"),
+		WithEnv(helperEnv + "=" + mode),
+	}, opts...)
+	return New(os.Args[0], opts...)
+}
+
+// TestHelperProcess isn't a test: it is the function run by the tests.
+func TestHelperProcess(t *testing.T) {
+	mode := os.Getenv(helperEnv)
+	if mode == "" {
+		return
+	}
+	in, _ := io.ReadAll(os.Stdin)
+	switch mode {
+	case "echo":
+		os.Stdout.Write(in)
+	case "env":
+		rctx, _ := fn.ParseResourceContext(in)
+		for _, k := range []string{"FN_CLIENT_INHERITED", "FN_CLIENT_OVERRIDDEN", "FN_CLIENT_ADDED"} {
+			rctx.LogResult(fn.GeneralResult(k+"="+os.Getenv(k), fn.Info))
+		}
+		out, _ := rctx.ToYAML()
+		os.Stdout.Write(out)
+	case "fail":
+		rctx, _ := fn.ParseResourceContext(in)
+		rctx.LogResult(fmt.Errorf("invalid origin"))
+		out, _ := rctx.ToYAML()
+		os.Stdout.Write(out)
+		os.Exit(1)
+	case "crash":
+		fmt.Fprintln(os.Stderr, "out of memory")
+		os.Exit(2)
+	case "garbage":
+		os.Stdout.WriteString("- not\n- a ResourceContext\n")
+	case "empty":
+	case "sleep":
+		time.Sleep(time.Minute)
+	case "flood":
+		line := bytes.Repeat([]byte("x"), 1024)
+		for {
+			if _, err := os.Stdout.Write(line); err != nil {
+				os.Exit(3)
+			}
+		}
+	case "noisy":
+		os.Stderr.Write(bytes.Repeat([]byte("x"), 2*DefaultMaxStderrSize))
+		os.Stdout.Write(in)
+	default:
+		helperModes(mode)
+	}
+	os.Exit(0)
+}
+
+func TestClientRun(t *testing.T) {
+	rctx, err := fn.ParseResourceContext([]byte(testInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := newHelperClient("echo").Run(context.Background(), rctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.ExitCode != 0 || resp.ResourceContext == nil {
+		t.Fatalf("expected a ResourceContext, got exit code %d and\n%s", resp.ExitCode, resp.Raw)
+	}
+	if name := resp.ResourceContext.Input.Origin.GetName(); name != "app1" {
+		t.Errorf("expected the origin app1, got %s", name)
+	}
+}
+
+func TestClientEnv(t *testing.T) {
+	t.Setenv("FN_CLIENT_INHERITED", "parent")
+	t.Setenv("FN_CLIENT_OVERRIDDEN", "parent")
+	resp, err := newHelperClient("env",
+		WithEnv("FN_CLIENT_OVERRIDDEN=option", "FN_CLIENT_ADDED=option"),
+	).RunBytes(context.Background(), []byte(testInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, result := range resp.Results() {
+		got = append(got, result.Message)
+	}
+	want := "FN_CLIENT_INHERITED=parent FN_CLIENT_OVERRIDDEN=option FN_CLIENT_ADDED=option"
+	if strings.Join(got, " ") != want {
+		t.Errorf("expected the environment %s, got %v", want, got)
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	tests := map[string]struct {
+		opts  []Option
+		check func(t *testing.T, resp *Response, err error)
+	}{
+		"fail": {
+			check: func(t *testing.T, resp *Response, err error) {
+				var exitErr *ExitError
+				if !errors.As(err, &exitErr) {
+					t.Fatalf("expected an ExitError, got %v", err)
+				}
+				if exitErr.ExitCode != 1 || len(exitErr.Results) != 1 {
+					t.Errorf("expected exit code 1 and the Results, got %d and %v", exitErr.ExitCode, exitErr.Results)
+				}
+				if !strings.HasSuffix(err.Error(), "exited with code 1: [error]: invalid origin") {
+					t.Errorf("expected the error to tell the error results, got %q", err.Error())
+				}
+				if resp.ResourceContext == nil {
+					t.Error("expected the returned ResourceContext")
+				}
+			},
+		},
+		"crash": {
+			check: func(t *testing.T, resp *Response, err error) {
+				var exitErr *ExitError
+				if !errors.As(err, &exitErr) || exitErr.ExitCode != 2 {
+					t.Fatalf("expected an ExitError with exit code 2, got %v", err)
+				}
+				if !strings.HasSuffix(err.Error(), "exited with code 2: out of memory") {
+					t.Errorf("expected the error to tell the logs, got %q", err.Error())
+				}
+			},
+		},
+		"garbage": {
+			check: func(t *testing.T, resp *Response, err error) {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) {
+					t.Fatalf("expected a ParseError, got %v", err)
+				}
+				if string(resp.Raw) != "- not\n- a ResourceContext\n" {
+					t.Errorf("expected the raw output, got %q", resp.Raw)
+				}
+			},
+		},
+		"empty": {
+			check: func(t *testing.T, resp *Response, err error) {
+				var parseErr *ParseError
+				if !errors.As(err, &parseErr) || !strings.Contains(err.Error(), "returned no ResourceContext") {
+					t.Fatalf("expected a ParseError, got %v", err)
+				}
+			},
+		},
+		"sleep": {
+			opts: []Option{WithTimeout(100 * time.Millisecond)},
+			check: func(t *testing.T, resp *Response, err error) {
+				var timeoutErr *TimeoutError
+				if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != 100*time.Millisecond {
+					t.Fatalf("expected a TimeoutError, got %v", err)
+				}
+				if resp.Duration > waitDelay {
+					t.Errorf("expected the function to be killed, it ran for %s", resp.Duration)
+				}
+			},
+		},
+		"flood": {
+			opts: []Option{WithMaxOutputSize(64 << 10)},
+			check: func(t *testing.T, resp *Response, err error) {
+				var limitErr *OutputLimitError
+				if !errors.As(err, &limitErr) || limitErr.Limit != 64<<10 {
+					t.Fatalf("expected an OutputLimitError, got %v", err)
+				}
+				if len(resp.Raw) > 64<<10 {
+					t.Errorf("expected the output to be capped, got %d bytes", len(resp.Raw))
+				}
+			},
+		},
+		"noisy": {
+			check: func(t *testing.T, resp *Response, err error) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(resp.Stderr) != DefaultMaxStderrSize {
+					t.Errorf("expected the logs to be capped to %d bytes, got %d", DefaultMaxStderrSize, len(resp.Stderr))
+				}
+			},
+		},
+	}
+	for mode, tc := range tests {
+		t.Run(mode, func(t *testing.T) {
+			resp, err := newHelperClient(mode, tc.opts...).RunBytes(context.Background(), []byte(testInput))
+			if resp == nil {
+				t.Fatalf("expected a Response, got error %v", err)
+			}
+			tc.check(t, resp, err)
+		})
+	}
+}
+
+func TestClientCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	resp, err := newHelperClient("sleep").RunBytes(ctx, []byte(testInput))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected the run to be canceled, got %v", err)
+	}
+	if resp.Duration > waitDelay {
+		t.Errorf("expected the function to be killed, it ran for %s", resp.Duration)
+	}
+}
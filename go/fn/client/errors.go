@@ -0,0 +1,72 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// ExitError is returned when the function exits with a non-zero code. The
+// function reports why in its Results and logs.
+type ExitError struct {
+	Path     string
+	ExitCode int
+	Stderr   string
+	Results  fn.Results
+}
+
+func (e *ExitError) Error() string {
+	msg := fmt.Sprintf("function %s exited with code %d", e.Path, e.ExitCode)
+	var errs fn.Results
+	for _, result := range e.Results {
+		if result.Severity == fn.Error {
+			errs = append(errs, result)
+		}
+	}
+	switch {
+	case len(errs) > 0:
+		msg += ": " + errs.Error()
+	case strings.TrimSpace(e.Stderr) != "":
+		msg += ": " + strings.TrimSpace(e.Stderr)
+	}
+	return msg
+}
+
+// TimeoutError is returned when the function doesn't return within the
+// timeout. The function is killed.
+type TimeoutError struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("function %s did not return within %s", e.Path, e.Timeout)
+}
+
+// OutputLimitError is returned when the function writes more than the maximum
+// output size. The function is killed.
+type OutputLimitError struct {
+	Path  string
+	Limit int64
+}
+
+func (e *OutputLimitError) Error() string {
+	return fmt.Sprintf("function %s wrote more than %d bytes", e.Path, e.Limit)
+}
+
+// ParseError is returned when the output of the function is not a
+// ResourceContext.
+type ParseError struct {
+	Path string
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("unable to parse the output of function %s: %v", e.Path, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
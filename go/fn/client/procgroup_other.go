@@ -0,0 +1,10 @@
+//go:build !unix
+
+package client
+
+import "os/exec"
+
+// killProcessGroup leaves cmd as is: without process groups, canceling cmd
+// kills the function only, and WaitDelay bounds the wait for the processes it
+// started.
+func killProcessGroup(cmd *exec.Cmd) {}
@@ -0,0 +1,6 @@
+//go:build !unix
+
+package client
+
+// helperModes runs the modes of TestHelperProcess that need a process group.
+func helperModes(mode string) {}
@@ -0,0 +1,55 @@
+//go:build unix
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// helperModes runs the modes of TestHelperProcess that need a process group.
+func helperModes(mode string) {
+	switch mode {
+	case "spawn":
+		// the grandchild inherits stdout, so the run only ends early when it
+		// is killed with the function
+		cmd := exec.Command(os.Args[0], "-test.run=^TestHelperProcess$")
+		cmd.Env = append(os.Environ(), helperEnv+"=sleep")
+		cmd.Stdout = os.Stdout
+		if err := cmd.Start(); err != nil {
+			os.Exit(4)
+		}
+		fmt.Fprintf(os.Stderr, "%d", cmd.Process.Pid)
+		time.Sleep(time.Minute)
+	}
+}
+
+func TestClientKillsProcessGroup(t *testing.T) {
+	start := time.Now()
+	resp, err := newHelperClient("spawn", WithTimeout(500*time.Millisecond)).RunBytes(context.Background(), []byte(testInput))
+	elapsed := time.Since(start)
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) {
+		t.Fatalf("expected a TimeoutError, got %v", err)
+	}
+	pid, convErr := strconv.Atoi(strings.TrimSpace(string(resp.Stderr)))
+	if convErr != nil {
+		t.Fatalf("expected the pid of the grandchild, got %q", resp.Stderr)
+	}
+	// in case it escaped, don't leave the grandchild behind
+	defer syscall.Kill(pid, syscall.SIGKILL)
+
+	// without killing the group, Wait waits for the grandchild to close stdout
+	// until waitDelay
+	if elapsed >= waitDelay {
+		t.Errorf("expected the grandchild to be killed with the function, the run took %s", elapsed)
+	}
+}
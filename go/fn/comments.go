@@ -0,0 +1,249 @@
+package fn
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+type commentKind int
+
+const (
+	headComment commentKind = iota
+	lineComment
+	footComment
+)
+
+func (k commentKind) String() string {
+	switch k {
+	case headComment:
+		return "head"
+	case lineComment:
+		return "line"
+	default:
+		return "foot"
+	}
+}
+
+// LineComment returns the line comment, if the target field exist and a
+// potential error.
+//
+// The comment methods address the field located by fields, or the object
+// itself when no fields are given, e.g. an element of a SliceSubObjects. The
+// comments are kept where the yaml parser puts them and the encoder writes
+// them: the head and foot comments of a field belong to its key, the line
+// comment of a field with a list or map value is the one after the key. The
+// line comment of an object is the one on its first line and its foot comment
+// the one after its last field, e.g.
+//
+//	# head comment of the element
+//	- name: a # line comment of the element and of its name
+//	  mtu: 1500
+//	  # foot comment of the element and of its mtu
+func (o *SubObject) LineComment(fields ...string) (string, bool, error) {
+	return o.comment(lineComment, fields...)
+}
+
+// HeadComment returns the head comment, if the target field exist and a
+// potential error.
+func (o *SubObject) HeadComment(fields ...string) (string, bool, error) {
+	return o.comment(headComment, fields...)
+}
+
+// FootComment returns the foot comment, if the target field exist and a
+// potential error.
+func (o *SubObject) FootComment(fields ...string) (string, bool, error) {
+	return o.comment(footComment, fields...)
+}
+
+// SetLineComment sets the line comment of the target field. It returns error
+// if the field doesn't exist.
+func (o *SubObject) SetLineComment(comment string, fields ...string) error {
+	return o.setComment(lineComment, comment, fields...)
+}
+
+// SetHeadComment sets the head comment of the target field. It returns error
+// if the field doesn't exist.
+func (o *SubObject) SetHeadComment(comment string, fields ...string) error {
+	return o.setComment(headComment, comment, fields...)
+}
+
+// SetFootComment sets the foot comment of the target field. It returns error
+// if the field doesn't exist.
+func (o *SubObject) SetFootComment(comment string, fields ...string) error {
+	return o.setComment(footComment, comment, fields...)
+}
+
+// CopyComments copies the comments of the field located by fields in from,
+// and of everything below it, to the same field in o. Fields are matched by
+// key and list elements by index; comments of o that have no counterpart in
+// from are kept. It is meant for outputs built from a field of an origin,
+// which don't keep the comments of the origin, e.g.
+//
+//	var spec map[string]interface{}
+//	origin.GetMap("spec").As(&spec)
+//	output.SetNestedField(spec, "spec")
+//	output.CopyComments(&origin.SubObject, "spec")
+func (o *SubObject) CopyComments(from *SubObject, fields ...string) error {
+	if o == nil || from == nil {
+		return fmt.Errorf("the object doesn't exist")
+	}
+	srcKey, src, found, err := from.obj.GetNestedEntry(fields...)
+	if err != nil {
+		return fmt.Errorf("unable to copy the comments of fields %v with error: %w", fields, err)
+	}
+	if !found {
+		return nil
+	}
+	dstKey, dst, found, err := o.obj.GetNestedEntry(fields...)
+	if err != nil {
+		return fmt.Errorf("unable to copy the comments of fields %v with error: %w", fields, err)
+	}
+	if !found {
+		return fmt.Errorf("can't copy comments because the field doesn't exist")
+	}
+	copyComments(dstKey, dst, srcKey, src)
+	return nil
+}
+
+// ProvenanceComment returns a comment recording that a value was generated
+// from the field located by fields in obj, e.g. "from App/default/app1: spec.mtu".
+func ProvenanceComment(obj *KubeObject, fields ...string) string {
+	id := obj.GetKind()
+	if ns := obj.GetNamespace(); ns != "" {
+		id += "/" + ns
+	}
+	id += "/" + obj.GetName()
+	if len(fields) == 0 {
+		return "from " + id
+	}
+	return fmt.Sprintf("from %s: %s", id, strings.Join(fields, "."))
+}
+
+// SetProvenanceComment sets the line comment of the target field to the
+// ProvenanceComment of the field located by fromFields in from, so the
+// outputs show where their values come from.
+func (o *SubObject) SetProvenanceComment(from *KubeObject, fromFields []string, fields ...string) error {
+	return o.SetLineComment(ProvenanceComment(from, fromFields...), fields...)
+}
+
+func (o *SubObject) comment(kind commentKind, fields ...string) (string, bool, error) {
+	if o == nil {
+		return "", false, fmt.Errorf("the object doesn't exist")
+	}
+	key, value, found, err := o.obj.GetNestedEntry(fields...)
+	if !found || err != nil {
+		return "", found, err
+	}
+	node := commentNode(kind, key, value)
+	if c := *commentOf(kind, node); c != "" || node == value {
+		return c, true, nil
+	}
+	// the parser may have put the comment on the value
+	return *commentOf(kind, value), true, nil
+}
+
+func (o *SubObject) setComment(kind commentKind, comment string, fields ...string) error {
+	if o == nil {
+		return fmt.Errorf("the object doesn't exist")
+	}
+	key, value, found, err := o.obj.GetNestedEntry(fields...)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("can't set %s comment because the field doesn't exist", kind)
+	}
+	setCommentNode(kind, comment, key, value)
+	return nil
+}
+
+// setCommentNode sets the comment of the entry key, value, removing the same
+// comment from the value so it isn't written twice.
+func setCommentNode(kind commentKind, comment string, key, value *yaml.Node) {
+	node := commentNode(kind, key, value)
+	*commentOf(kind, node) = comment
+	if node != value {
+		*commentOf(kind, value) = ""
+	}
+}
+
+// commentNode returns the node holding the comment of the entry key, value,
+// where key is nil for an object or a list element.
+func commentNode(kind commentKind, key, value *yaml.Node) *yaml.Node {
+	block := (value.Kind == yaml.MappingNode || value.Kind == yaml.SequenceNode) &&
+		value.Style&yaml.FlowStyle == 0 && len(value.Content) > 0
+	switch kind {
+	case headComment:
+		if key != nil {
+			return key
+		}
+	case lineComment:
+		if !block {
+			return value
+		}
+		if key != nil {
+			return key
+		}
+		// the first line of a block belongs to its first entry
+		if value.Kind == yaml.MappingNode {
+			return commentNode(kind, value.Content[0], value.Content[1])
+		}
+		return commentNode(kind, nil, value.Content[0])
+	case footComment:
+		if key != nil {
+			return key
+		}
+		// the encoder writes the foot comment of a block map after the
+		// value of its last entry
+		if block && value.Kind == yaml.MappingNode {
+			return value.Content[len(value.Content)-2]
+		}
+	}
+	return value
+}
+
+func commentOf(kind commentKind, node *yaml.Node) *string {
+	switch kind {
+	case headComment:
+		return &node.HeadComment
+	case lineComment:
+		return &node.LineComment
+	default:
+		return &node.FootComment
+	}
+}
+
+// copyComments copies the comments of the entry srcKey, src and below to the
+// entry dstKey, dst.
+func copyComments(dstKey, dst, srcKey, src *yaml.Node) {
+	for _, kind := range []commentKind{headComment, lineComment, footComment} {
+		node := commentNode(kind, srcKey, src)
+		c := *commentOf(kind, node)
+		if c == "" && node != src {
+			c = *commentOf(kind, src)
+		}
+		if c != "" {
+			setCommentNode(kind, c, dstKey, dst)
+		}
+	}
+	if src.Kind != dst.Kind {
+		return
+	}
+	switch src.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			for j := 0; j+1 < len(dst.Content); j += 2 {
+				if dst.Content[j].Value == src.Content[i].Value {
+					copyComments(dst.Content[j], dst.Content[j+1], src.Content[i], src.Content[i+1])
+					break
+				}
+			}
+		}
+	case yaml.SequenceNode:
+		for i := 0; i < len(src.Content) && i < len(dst.Content); i++ {
+			copyComments(nil, dst.Content[i], nil, src.Content[i])
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package fn
+
+import (
+	"testing"
+)
+
+const testComments = `apiVersion: v1 # line of apiVersion
+kind: ConfigMap
+metadata:
+  name: cm
+# head of spec
+spec: # line of spec
+  # head of mtu
+  mtu: 1500 # line of mtu
+  # foot of mtu
+
+  flowMap: {a: b} # line of flowMap
+  flowList: [a, b] # line of flowList
+  # head of list
+  list: # line of list
+  # head of element
+  - name: a # line of element
+    vlan: 1
+    # foot of element
+  - name: b # line of second element
+  emptyMap: {} # line of emptyMap
+`
+
+func TestCommentPlacement(t *testing.T) {
+	tests := map[string]struct {
+		fields           []string
+		head, line, foot string
+	}{
+		"object":     {line: "# line of apiVersion"},
+		"block map":  {fields: []string{"spec"}, head: "# head of spec", line: "# line of spec"},
+		"scalar":     {fields: []string{"spec", "mtu"}, head: "# head of mtu", line: "# line of mtu", foot: "# foot of mtu"},
+		"flow map":   {fields: []string{"spec", "flowMap"}, line: "# line of flowMap"},
+		"flow list":  {fields: []string{"spec", "flowList"}, line: "# line of flowList"},
+		"block list": {fields: []string{"spec", "list"}, head: "# head of list", line: "# line of list"},
+		"empty map":  {fields: []string{"spec", "emptyMap"}, line: "# line of emptyMap"},
+		"no comment": {fields: []string{"metadata", "name"}},
+	}
+	obj, err := ParseKubeObject([]byte(testComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			for kind, get := range map[string]func(...string) (string, bool, error){
+				"head": obj.HeadComment, "line": obj.LineComment, "foot": obj.FootComment,
+			} {
+				want := map[string]string{"head": tc.head, "line": tc.line, "foot": tc.foot}[kind]
+				got, found, err := get(tc.fields...)
+				if err != nil || !found {
+					t.Fatalf("expected the field, got %v, %v", found, err)
+				}
+				if got != want {
+					t.Errorf("expected the %s comment %q, got %q", kind, want, got)
+				}
+			}
+		})
+	}
+
+	elements := obj.GetMap("spec").GetSlice("list")
+	for i, want := range map[int][3]string{
+		0: {"# head of element", "# line of element", "# foot of element"},
+		1: {"", "# line of second element", ""},
+	} {
+		head, _, _ := elements[i].HeadComment()
+		line, _, _ := elements[i].LineComment()
+		foot, _, _ := elements[i].FootComment()
+		if got := [3]string{head, line, foot}; got != want {
+			t.Errorf("expected the comments %q of element %d, got %q", want, i, got)
+		}
+	}
+
+	if _, found, err := obj.LineComment("spec", "missing"); found || err != nil {
+		t.Errorf("expected a missing field not to be found, got %v, %v", found, err)
+	}
+}
+
+func TestSetComments(t *testing.T) {
+	obj, err := ParseKubeObject([]byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+spec:
+  mtu: 1500
+  flowMap: {a: b}
+  list:
+  - name: a
+    vlan: 1
+  - name: b
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	set := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	set(obj.SetHeadComment("head of spec", "spec"))
+	set(obj.SetLineComment("line of spec", "spec"))
+	set(obj.SetLineComment("line of mtu", "spec", "mtu"))
+	set(obj.SetFootComment("foot of mtu", "spec", "mtu"))
+	set(obj.SetLineComment("line of flowMap", "spec", "flowMap"))
+	set(obj.SetLineComment("line of list", "spec", "list"))
+	elements := obj.GetMap("spec").GetSlice("list")
+	set(elements[0].SetHeadComment("head of element"))
+	set(elements[0].SetLineComment("line of element"))
+	set(elements[0].SetFootComment("foot of element"))
+	set(elements[1].SetLineComment("line of second element"))
+	if err := obj.SetLineComment("c", "spec", "missing"); err == nil {
+		t.Error("expected an error for a missing field")
+	}
+
+	want := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: cm
+# head of spec
+spec: # line of spec
+  mtu: 1500 # line of mtu
+  # foot of mtu
+
+  flowMap: {a: b} # line of flowMap
+  list: # line of list
+  # head of element
+  - name: a # line of element
+    vlan: 1
+    # foot of element
+  - name: b # line of second element
+`
+	if got := obj.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+}
+
+// TestKubeObjectComments checks that the comment methods a KubeObject gets
+// from its SubObject address the same nodes as on any SubObject.
+func TestKubeObjectComments(t *testing.T) {
+	parse := func() *KubeObject {
+		obj, err := ParseKubeObject([]byte(testComments))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	obj := parse()
+	spec := obj.GetMap("spec")
+	for _, field := range []string{"mtu", "flowMap", "list"} {
+		fromObj, _, err := obj.LineComment("spec", field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fromSpec, _, err := spec.LineComment(field)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fromObj != fromSpec {
+			t.Errorf("expected the line comment of %s to be %q on the KubeObject, got %q", field, fromSpec, fromObj)
+		}
+	}
+
+	viaObj, viaSub := parse(), parse()
+	if err := viaObj.SetLineComment("# changed", "spec", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if err := viaSub.GetMap("spec").SetLineComment("# changed", "list"); err != nil {
+		t.Fatal(err)
+	}
+	if viaObj.String() != viaSub.String() {
+		t.Errorf("expected SetLineComment on the KubeObject to write\n%s\ngot\n%s", viaSub, viaObj)
+	}
+	// without fields, the comment is the one of the object itself
+	if err := viaObj.SetLineComment("# object"); err != nil {
+		t.Fatal(err)
+	}
+	if line, _, _ := viaObj.LineComment("apiVersion"); line != "# object" {
+		t.Errorf("expected the line comment of the object on its first line, got %q", line)
+	}
+}
+
+func TestCopyComments(t *testing.T) {
+	origin, err := ParseKubeObject([]byte(testComments))
+	if err != nil {
+		t.Fatal(err)
+	}
+	output, err := ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: out\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var spec map[string]interface{}
+	if err := origin.GetMap("spec").As(&spec); err != nil {
+		t.Fatal(err)
+	}
+	// the output has a list element fewer and a field of its own, and its
+	// values are in block style
+	spec["list"] = []interface{}{spec["list"].([]interface{})[0]}
+	spec["extra"] = "c"
+	if err := output.SetNestedField(spec, "spec"); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.SetLineComment("# line of extra", "spec", "extra"); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.CopyComments(&origin.SubObject, "spec"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: out
+# head of spec
+spec: # line of spec
+  emptyMap: {} # line of emptyMap
+  extra: c # line of extra
+  flowList: # line of flowList
+  - a
+  - b
+  flowMap: # line of flowMap
+    a: b
+  # head of list
+  list: # line of list
+  # head of element
+  - name: a # line of element
+    vlan: 1
+    # foot of element
+  # head of mtu
+  mtu: 1500 # line of mtu
+  # foot of mtu
+`
+	if got := output.String(); got != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, got)
+	}
+
+	if err := output.CopyComments(&origin.SubObject, "spec", "missing"); err != nil {
+		t.Errorf("expected a field missing in the source to be skipped, got %v", err)
+	}
+	if err := output.CopyComments(&origin.SubObject, "metadata", "name"); err != nil {
+		t.Fatal(err)
+	}
+	if err := output.CopyComments(&origin.SubObject, "spec", "mtu", "x"); err == nil {
+		t.Error("expected an error for a path through a scalar")
+	}
+}
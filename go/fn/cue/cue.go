@@ -0,0 +1,294 @@
+// Package cue provides a ResourceContextProcessor that maps the inputs of a
+// function to its outputs declaratively, by unifying them with a CUE package.
+//
+// The origin, the target, the items and the functionConfig are filled into the
+// fields `origin`, `target`, `items` and `functionConfig` of the package, and
+// the outputs are extracted from its `outputs` field, a list or a struct of
+// objects, e.g.
+//
+//	origin: spec: mtu: <=9216
+//	items: [...]
+//	outputs: [ for i in items if i.kind == "Interface" {
+//	    i & {spec: mtu: origin.spec.mtu}
+//	}]
+//
+// Validation errors of the unified value are reported as Results pointing at
+// the object and field they occurred in.
+package cue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/encoding/yaml"
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+const (
+	// OriginField is the field the origin is filled into.
+	OriginField = "origin"
+	// TargetField is the field the target is filled into.
+	TargetField = "target"
+	// ItemsField is the field the items are filled into, as a list.
+	ItemsField = "items"
+	// FunctionConfigField is the field the functionConfig is filled into.
+	FunctionConfigField = "functionConfig"
+	// OutputsField is the default field the outputs are extracted from.
+	OutputsField = "outputs"
+
+	// PositionTag is the Result tag holding the CUE source position of an
+	// error.
+	PositionTag = "position"
+)
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithOutputs sets the path of the field the outputs are extracted from, e.g.
+// "render.outputs". It defaults to OutputsField.
+func WithOutputs(path string) Option {
+	return func(p *Processor) {
+		p.outputs = cue.ParsePath(path)
+	}
+}
+
+// Processor unifies the ResourceContext with a CUE value and extracts the
+// outputs from the result. CUE values are not safe for concurrent use, so the
+// calls of a Processor, e.g. by fn.Serve, are serialized.
+type Processor struct {
+	// mu guards the CUE runtime of value, which every call uses.
+	mu      sync.Mutex
+	value   cue.Value
+	outputs cue.Path
+}
+
+// Load returns a Processor for the CUE package in dir.
+func Load(dir string, opts ...Option) (*Processor, error) {
+	instances := load.Instances([]string{"."}, &load.Config{Dir: dir})
+	if len(instances) != 1 {
+		return nil, fmt.Errorf("expected one CUE instance in %s, got %d", dir, len(instances))
+	}
+	if err := instances[0].Err; err != nil {
+		return nil, fmt.Errorf("unable to load CUE package %s: %w", dir, err)
+	}
+	v := cuecontext.New().BuildInstance(instances[0])
+	if err := v.Err(); err != nil {
+		return nil, fmt.Errorf("unable to build CUE package %s: %w", dir, err)
+	}
+	return newProcessor(v, opts...)
+}
+
+// Compile returns a Processor for the CUE source src.
+func Compile(src string, opts ...Option) (*Processor, error) {
+	v := cuecontext.New().CompileString(src)
+	if err := v.Err(); err != nil {
+		return nil, fmt.Errorf("unable to compile CUE: %w", err)
+	}
+	return newProcessor(v, opts...)
+}
+
+func newProcessor(v cue.Value, opts ...Option) (*Processor, error) {
+	p := &Processor{value: v, outputs: cue.ParsePath(OutputsField)}
+	for _, opt := range opts {
+		opt(p)
+	}
+	if err := p.outputs.Err(); err != nil {
+		return nil, fmt.Errorf("invalid outputs path: %w", err)
+	}
+	return p, nil
+}
+
+// Process unifies the inputs of rctx with the CUE value and adds the objects of
+// the outputs field to the outputs. Validation errors are reported as Results.
+func (p *Processor) Process(rctx *fn.ResourceContext) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	v, err := p.unify(rctx)
+	if err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+	out := v.LookupPath(p.outputs)
+	if !out.Exists() {
+		err := fmt.Errorf("the CUE value has no %s", p.outputs)
+		rctx.LogResult(err)
+		return false, err
+	}
+	if results := p.results(rctx, v.Validate(), out.Validate(cue.Concrete(true))); len(results) > 0 {
+		rctx.LogResult(results)
+		return false, results
+	}
+	outputs, err := objects(out)
+	if err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+	rctx.Outputs = append(rctx.Outputs, outputs...)
+	return true, nil
+}
+
+// Unify returns the CUE value unified with the inputs of rctx. The value shares
+// the CUE runtime of the Processor, so it must not be used while the Processor
+// processes another ResourceContext.
+func (p *Processor) Unify(rctx *fn.ResourceContext) (cue.Value, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.unify(rctx)
+}
+
+func (p *Processor) unify(rctx *fn.ResourceContext) (cue.Value, error) {
+	v := p.value
+	items := []cue.Value{}
+	if rctx.Input != nil {
+		for _, input := range []struct {
+			field string
+			obj   *fn.KubeObject
+		}{
+			{OriginField, rctx.Input.Origin},
+			{TargetField, rctx.Input.Target},
+			{FunctionConfigField, rctx.Input.FunctionConfig},
+		} {
+			if input.obj == nil {
+				continue
+			}
+			value, err := p.toValue(input.field, input.obj)
+			if err != nil {
+				return v, fmt.Errorf("unable to convert the %s: %w", input.field, err)
+			}
+			v = v.FillPath(cue.ParsePath(input.field), value)
+		}
+		for i, item := range rctx.Input.Items {
+			value, err := p.toValue(fmt.Sprintf("%s[%d]", ItemsField, i), item)
+			if err != nil {
+				return v, fmt.Errorf("unable to convert the items: %w", err)
+			}
+			items = append(items, value)
+		}
+	}
+	return v.FillPath(cue.ParsePath(ItemsField), items), nil
+}
+
+// toValue converts obj to a CUE value from its yaml, so integers stay integers.
+// Positions in the value refer to name.
+func (p *Processor) toValue(name string, obj *fn.KubeObject) (cue.Value, error) {
+	f, err := yaml.Extract(name, obj.String())
+	if err != nil {
+		return cue.Value{}, err
+	}
+	v := p.value.Context().BuildFile(f)
+	return v, v.Err()
+}
+
+// results converts the CUE errors to Results, leaving out duplicates.
+func (p *Processor) results(rctx *fn.ResourceContext, errs ...error) fn.Results {
+	var results fn.Results
+	seen := map[string]bool{}
+	for _, err := range errs {
+		for _, e := range errors.Errors(err) {
+			msg := e.Error()
+			if seen[msg] {
+				continue
+			}
+			seen[msg] = true
+			results = append(results, errorResult(rctx, e, msg))
+		}
+	}
+	return results
+}
+
+// errorResult returns a Result for e that refers to the input object and field
+// it occurred in, or to the field of the CUE value for errors outside the
+// inputs.
+func errorResult(rctx *fn.ResourceContext, e errors.Error, msg string) *fn.Result {
+	path := e.Path()
+	var result *fn.Result
+	if obj, rest := inputObject(rctx, path); obj != nil {
+		if len(rest) > 0 {
+			result = fn.ConfigFieldResult(msg, obj, fn.Field{Path: strings.Join(rest, ".")}, fn.Error)
+		} else {
+			result = fn.ConfigObjectResult(msg, obj, fn.Error)
+		}
+	} else {
+		result = fn.GeneralResult(msg, fn.Error)
+		if len(path) > 0 {
+			result.Field = &fn.Field{Path: strings.Join(path, ".")}
+		}
+	}
+	for _, pos := range errors.Positions(e) {
+		if pos.IsValid() {
+			result.Tags = map[string]string{PositionTag: pos.String()}
+			break
+		}
+	}
+	return result
+}
+
+// inputObject returns the input object path points into and the path within
+// that object.
+func inputObject(rctx *fn.ResourceContext, path []string) (*fn.KubeObject, []string) {
+	if rctx.Input == nil || len(path) == 0 {
+		return nil, nil
+	}
+	switch path[0] {
+	case OriginField:
+		return rctx.Input.Origin, path[1:]
+	case TargetField:
+		return rctx.Input.Target, path[1:]
+	case FunctionConfigField:
+		return rctx.Input.FunctionConfig, path[1:]
+	case ItemsField:
+		if len(path) < 2 {
+			return nil, nil
+		}
+		i, err := strconv.Atoi(path[1])
+		if err != nil || i < 0 || i >= len(rctx.Input.Items) {
+			return nil, nil
+		}
+		return rctx.Input.Items[i], path[2:]
+	}
+	return nil, nil
+}
+
+// objects returns the objects of v, a list or a struct of objects.
+func objects(v cue.Value) (fn.KubeObjects, error) {
+	var elems []cue.Value
+	switch v.Kind() {
+	case cue.ListKind:
+		iter, err := v.List()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			elems = append(elems, iter.Value())
+		}
+	case cue.StructKind:
+		iter, err := v.Fields()
+		if err != nil {
+			return nil, err
+		}
+		for iter.Next() {
+			elems = append(elems, iter.Value())
+		}
+	default:
+		return nil, fmt.Errorf("outputs must be a list or a struct of objects, got %s", v.Kind())
+	}
+	objs := fn.KubeObjects{}
+	for _, elem := range elems {
+		b, err := yaml.Encode(elem)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", elem.Path(), err)
+		}
+		obj, err := fn.ParseKubeObject(b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", elem.Path(), err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
@@ -0,0 +1,93 @@
+package cue
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+const src = `
+origin: spec: mtu: <=9216
+items: [...]
+outputs: [ for i in items if i.kind == "Interface" {
+	i & {spec: mtu: origin.spec.mtu}
+}]
+`
+
+func resourceContext(t *testing.T, mtu int) *fn.ResourceContext {
+	rctx, err := fn.ParseResourceContext([]byte(fmt.Sprintf(`apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+    spec:
+      mtu: %d
+  items:
+  - apiVersion: v1
+    kind: Interface
+    metadata:
+      name: eth0
+  - apiVersion: v1
+    kind: Node
+    metadata:
+      name: node1
+`, mtu)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rctx
+}
+
+func TestProcess(t *testing.T) {
+	p, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rctx := resourceContext(t, 1500)
+	if ok, err := p.Process(rctx); !ok || err != nil {
+		t.Fatalf("unexpected failure %v", err)
+	}
+	if len(rctx.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(rctx.Outputs))
+	}
+	if mtu, _, _ := rctx.Outputs[0].NestedInt64("spec", "mtu"); mtu != 1500 {
+		t.Errorf("expected the mtu of the origin, got %d", mtu)
+	}
+
+	rctx = resourceContext(t, 10000)
+	if ok, err := p.Process(rctx); ok || err == nil {
+		t.Fatal("expected a validation error")
+	}
+	if len(rctx.Results) == 0 || rctx.Results[0].Field == nil || rctx.Results[0].Field.Path != "spec.mtu" {
+		t.Errorf("expected a Result for the mtu of the origin, got %v", rctx.Results)
+	}
+}
+
+func TestProcessParallel(t *testing.T) {
+	p, err := Compile(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+		go func(mtu int) {
+			defer wg.Done()
+			rctx := resourceContext(t, mtu)
+			if ok, err := p.Process(rctx); !ok || err != nil {
+				t.Errorf("unexpected failure %v", err)
+				return
+			}
+			if got, _, _ := rctx.Outputs[0].NestedInt64("spec", "mtu"); got != int64(mtu) {
+				t.Errorf("expected mtu %d, got %d", mtu, got)
+			}
+		}(1500 + i)
+	}
+	wg.Wait()
+}
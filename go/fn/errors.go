@@ -1,6 +1,8 @@
 package fn
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 )
@@ -9,7 +11,7 @@ import (
 type ErrMissingFnConfig struct{}
 
 func (ErrMissingFnConfig) Error() string {
-	return "unable to find the functionConfig in the resourceList"
+	return "unable to find the functionConfig in the ResourceContext"
 }
 
 // errKubeObjectFields raises if the KubeObject operation panics.
@@ -32,9 +34,14 @@ func (e *errSubObjectFields) Error() string {
 	return fmt.Sprintf("SubObject has unmatched field type: `%v", strings.Join(e.fields, "/"))
 }
 
+// errResultEnd raises if the processor terminates early with Exit, Fail or
+// Skip.
 type errResultEnd struct {
-	obj     *KubeObject
-	message string
+	obj      *KubeObject
+	message  string
+	severity Severity
+	// skip drops the outputs built so far.
+	skip bool
 }
 
 func (e *errResultEnd) Error() string {
@@ -42,4 +49,37 @@ func (e *errResultEnd) Error() string {
 		return fmt.Sprintf("function is terminated by %v: %v", e.obj.ShortString(), e.message)
 	}
 	return fmt.Sprintf("function is terminated: %v", e.message)
-}
\ No newline at end of file
+}
+
+// errProcessInterrupted raises if the processor did not return before its
+// context was done.
+type errProcessInterrupted struct {
+	cause error
+}
+
+func (e *errProcessInterrupted) Error() string {
+	if errors.Is(e.cause, context.DeadlineExceeded) {
+		return "function is terminated: deadline exceeded before processing finished"
+	}
+	return fmt.Sprintf("function is terminated: %v", e.cause)
+}
+
+func (e *errProcessInterrupted) Unwrap() error {
+	return e.cause
+}
+
+// errProcessPanic raises if the processor panics with a value other than the
+// errors above.
+type errProcessPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (e *errProcessPanic) Error() string {
+	return fmt.Sprintf("function panicked: %v", e.value)
+}
+
+func (e *errProcessPanic) Unwrap() error {
+	err, _ := e.value.(error)
+	return err
+}
@@ -0,0 +1,36 @@
+package fn
+
+import "fmt"
+
+// Exit stops the processor and returns cleanly from Run. The outputs built so
+// far are kept and the message is recorded as an info Result referencing obj,
+// or the origin if obj is nil.
+func Exit(obj *KubeObject, format string, args ...interface{}) {
+	panic(&errResultEnd{obj: obj, message: fmt.Sprintf(format, args...), severity: Info})
+}
+
+// Fail stops the processor and makes Run return an error. The outputs built so
+// far are kept and the message is recorded as an error Result referencing obj,
+// or the origin if obj is nil.
+func Fail(obj *KubeObject, format string, args ...interface{}) {
+	panic(&errResultEnd{obj: obj, message: fmt.Sprintf(format, args...), severity: Error})
+}
+
+// Skip stops the processor and returns cleanly from Run without any outputs,
+// e.g. when the origin doesn't need to be rendered. The message is recorded as
+// an info Result referencing obj, or the origin if obj is nil.
+func Skip(obj *KubeObject, format string, args ...interface{}) {
+	panic(&errResultEnd{obj: obj, message: fmt.Sprintf(format, args...), severity: Info, skip: true})
+}
+
+// result converts the early termination to the Result recorded in rctx.
+func (e *errResultEnd) result(rctx *ResourceContext) *Result {
+	result := GeneralResult(e.message, e.severity)
+	switch {
+	case e.obj != nil:
+		result.ResourceRef = e.obj.resourceIdentifier()
+	case rctx.Input != nil && rctx.Input.Origin != nil:
+		result.ResourceRef = rctx.Input.Origin.resourceIdentifier()
+	}
+	return result
+}
@@ -0,0 +1,74 @@
+package fn
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRunContextEndEarly(t *testing.T) {
+	tests := map[string]struct {
+		end      func(output *KubeObject)
+		fails    bool
+		severity Severity
+		outputs  int
+		// ref is the name of the object the Result refers to
+		ref string
+	}{
+		"exit": {
+			end:      func(output *KubeObject) { Exit(nil, "nothing left to %s", "render") },
+			severity: Info,
+			outputs:  1,
+			ref:      "app1",
+		},
+		"exit with object": {
+			end:      func(output *KubeObject) { Exit(output, "nothing left to %s", "render") },
+			severity: Info,
+			outputs:  1,
+			ref:      "cm",
+		},
+		"fail": {
+			end:      func(output *KubeObject) { Fail(nil, "nothing left to %s", "render") },
+			fails:    true,
+			severity: Error,
+			outputs:  1,
+			ref:      "app1",
+		},
+		"skip": {
+			end:      func(output *KubeObject) { Skip(output, "nothing left to %s", "render") },
+			severity: Info,
+			ref:      "cm",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+				output := testOutput(t)
+				rctx.Outputs = append(rctx.Outputs, output)
+				tc.end(output)
+				t.Error("expected the processor to stop")
+				return true, nil
+			})
+			out, err := runWithin(t, context.Background(), p)
+			if (err != nil) != tc.fails {
+				t.Errorf("expected the run to fail: %v, got %v", tc.fails, err)
+			}
+			rctx, parseErr := ParseResourceContext(out)
+			if parseErr != nil {
+				t.Fatal(parseErr)
+			}
+			if len(rctx.Outputs) != tc.outputs {
+				t.Errorf("expected %d outputs, got %d", tc.outputs, len(rctx.Outputs))
+			}
+			if len(rctx.Results) != 1 {
+				t.Fatalf("expected a single Result, got %v", rctx.Results)
+			}
+			result := rctx.Results[0]
+			if result.Severity != tc.severity || result.Message != "nothing left to render" {
+				t.Errorf("expected an %s Result with the message, got %v", tc.severity, result)
+			}
+			if result.ResourceRef == nil || result.ResourceRef.Name != tc.ref {
+				t.Errorf("expected the Result to refer to %s, got %+v", tc.ref, result.ResourceRef)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package fnrun
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/yndd/app-functions-sdk/go/fn/client"
+	"github.com/yndd/app-functions-sdk/go/fn/wasm"
+)
+
+// DefaultOutputDir is the directory the outputs are written to by default.
+const DefaultOutputDir = "out"
+
+// Main is the entrypoint of the fnrun command, see Execute. It exits the
+// process with a non-zero code on failure.
+func Main(r Runner) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := Execute(ctx, os.Args[1:], os.Stdout, os.Stderr, r); err != nil {
+		fmt.Fprintf(os.Stderr, "fnrun: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Execute runs the fnrun command:
+//
+//	fnrun --origin file [--target file] [--items path]... [--function-config file] [--output dir] [--watch] [--] binary [args...]
+//
+// The ResourceContext is built from the given files, the function binary is
+// executed on it and the outputs are written as separate files to the output
+// directory along with ResultsFile. A binary with the .wasm extension runs as a
+// WebAssembly module in process, see Wasm. If r is not nil, r runs the function in
+// process and no binary is given. With --watch, the function runs again
+// whenever its inputs, the binary or a --watch-path change, see Watch.
+func Execute(ctx context.Context, args []string, stdout, stderr io.Writer, r Runner) error {
+	c := &Config{}
+	fs := flag.NewFlagSet("fnrun", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.Origin, "origin", "", "file holding the origin")
+	fs.StringVar(&c.Target, "target", "", "file holding the target")
+	fs.Var((*stringsFlag)(&c.Items), "items", "file or directory holding input items, can be repeated")
+	fs.StringVar(&c.FunctionConfig, "function-config", "", "file holding the functionConfig")
+	fs.StringVar(&c.OutputDir, "output", DefaultOutputDir, "directory the outputs and results are written to")
+	timeout := fs.Duration("timeout", client.DefaultTimeout, "maximum duration of the function binary run")
+	maxOutputSize := fs.Int64("max-output-size", client.DefaultMaxOutputSize, "maximum size in bytes of the function binary output")
+	maxMemory := fs.Uint64("max-memory", wasm.DefaultMemoryLimit, "maximum memory in bytes of a .wasm function")
+	watch := fs.Bool("watch", false, "run the function again whenever an input or watched file changes")
+	watchOpts := WatchOptions{}
+	fs.Var((*stringsFlag)(&watchOpts.Paths), "watch-path", "file or directory watched in addition to the inputs, e.g. the function source, can be repeated")
+	fs.DurationVar(&watchOpts.Interval, "watch-interval", DefaultWatchInterval, "interval the watched files are polled at")
+	fs.Usage = func() {
+		fmt.Fprintf(stderr, "Usage: fnrun --origin file [flags] [--] binary [args...]\n\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch {
+	case r == nil && fs.NArg() == 0:
+		fs.Usage()
+		return fmt.Errorf("a function binary is required")
+	case r == nil:
+		// a rebuilt function binary triggers a run too
+		if _, err := os.Stat(fs.Arg(0)); err == nil {
+			watchOpts.Paths = append(watchOpts.Paths, fs.Arg(0))
+		}
+		if filepath.Ext(fs.Arg(0)) == ".wasm" {
+			r = Wasm(fs.Arg(0),
+				wasm.WithArgs(fs.Args()[1:]...),
+				wasm.WithStderr(stderr),
+				wasm.WithTimeout(*timeout),
+				wasm.WithMaxOutputSize(*maxOutputSize),
+				wasm.WithMemoryLimit(*maxMemory))
+			break
+		}
+		r = Exec(fs.Arg(0),
+			client.WithArgs(fs.Args()[1:]...),
+			client.WithStderr(stderr),
+			client.WithTimeout(*timeout),
+			client.WithMaxOutputSize(*maxOutputSize))
+	case fs.NArg() > 0:
+		return fmt.Errorf("unexpected arguments %v, the function runs in process", fs.Args())
+	}
+	if *watch {
+		return Watch(ctx, c, r, stdout, watchOpts)
+	}
+	_, err := Run(ctx, c, r, stdout)
+	return err
+}
+
+// stringsFlag is a flag that can be repeated and holds comma separated values.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string {
+	return strings.Join(*f, ",")
+}
+
+func (f *stringsFlag) Set(s string) error {
+	*f = append(*f, strings.Split(s, ",")...)
+	return nil
+}
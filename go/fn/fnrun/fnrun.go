@@ -0,0 +1,323 @@
+// Package fnrun runs a function locally on a ResourceContext that is built from
+// separate files, so a function can be tried without an orchestrator.
+package fnrun
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/client"
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+	"github.com/yndd/app-functions-sdk/go/fn/wasm"
+)
+
+// ResultsFile is the file the Results are written to in the output directory.
+const ResultsFile = "results.yaml"
+
+// Runner runs a function on a ResourceContext in yaml and returns the
+// resulting ResourceContext in yaml.
+type Runner interface {
+	Run(ctx context.Context, in []byte) ([]byte, error)
+}
+
+// RunnerFunc is an adapter to use an ordinary function as a Runner.
+type RunnerFunc func(ctx context.Context, in []byte) ([]byte, error)
+
+func (f RunnerFunc) Run(ctx context.Context, in []byte) ([]byte, error) {
+	return f(ctx, in)
+}
+
+// InProcess returns a Runner that runs p in the current process.
+func InProcess(p fn.ContextProcessor, opts ...fn.RunOption) Runner {
+	return RunnerFunc(func(ctx context.Context, in []byte) ([]byte, error) {
+		return fn.RunContext(ctx, p, in, opts...)
+	})
+}
+
+// Exec returns a Runner that executes the function binary at path with a
+// client.Client configured by opts.
+func Exec(path string, opts ...client.Option) Runner {
+	c := client.New(path, opts...)
+	return RunnerFunc(func(ctx context.Context, in []byte) ([]byte, error) {
+		resp, err := c.RunBytes(ctx, in)
+		if resp == nil {
+			return nil, err
+		}
+		return resp.Raw, err
+	})
+}
+
+// Wasm returns a Runner that runs the WebAssembly module at path with a
+// wasm.Runner configured by opts. The module is loaded for every run, so a
+// rebuilt module is picked up; the compiled code of an unchanged module is
+// reused.
+func Wasm(path string, opts ...wasm.Option) Runner {
+	opts = append([]wasm.Option{wasm.WithCompilationCache(wazero.NewCompilationCache())}, opts...)
+	return RunnerFunc(func(ctx context.Context, in []byte) ([]byte, error) {
+		r, err := wasm.Load(ctx, path, opts...)
+		if err != nil {
+			return nil, err
+		}
+		defer r.Close(ctx)
+		return r.Run(ctx, in)
+	})
+}
+
+// Config holds the files the ResourceContext is built from and the directory
+// the outputs are written to.
+type Config struct {
+	// Origin is the file holding the origin.
+	Origin string
+	// Target is the file holding the target, if any.
+	Target string
+	// Items are files or directories holding the additional input items.
+	Items []string
+	// FunctionConfig is the file holding the functionConfig, if any.
+	FunctionConfig string
+	// OutputDir is the directory the outputs and ResultsFile are written to.
+	// If empty, nothing is written.
+	OutputDir string
+}
+
+// ResourceContext builds the ResourceContext from the files of the Config.
+func (c *Config) ResourceContext() (*fn.ResourceContext, error) {
+	rctx, _, err := c.resourceContext()
+	return rctx, err
+}
+
+// resourceContext builds the ResourceContext and returns the Format of the
+// origin file, which the outputs are written in.
+func (c *Config) resourceContext() (*fn.ResourceContext, fn.Format, error) {
+	if c.Origin == "" {
+		return nil, fn.Format{}, fmt.Errorf("an origin is required")
+	}
+	rctx := &fn.ResourceContext{Input: &fn.ResourceContextInputs{}}
+	var f fn.Format
+	var err error
+	if rctx.Input.Origin, f, err = readObject(c.Origin); err != nil {
+		return nil, f, err
+	}
+	if c.Target != "" {
+		if rctx.Input.Target, _, err = readObject(c.Target); err != nil {
+			return nil, f, err
+		}
+	}
+	if c.FunctionConfig != "" {
+		if rctx.Input.FunctionConfig, _, err = readObject(c.FunctionConfig); err != nil {
+			return nil, f, err
+		}
+	}
+	if rctx.Input.Items, err = ReadObjects(c.Items...); err != nil {
+		return nil, f, err
+	}
+	return rctx, f, nil
+}
+
+// ReadObjects reads the KubeObjects from the given files and from the yaml and
+// json files in the given directories, recursively.
+func ReadObjects(paths ...string) (fn.KubeObjects, error) {
+	var objs fn.KubeObjects
+	for _, path := range paths {
+		var files []string
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			// files given explicitly are read whatever their extension
+			if file == path || isManifest(file) {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			in, err := ioutil.ReadFile(file)
+			if err != nil {
+				return nil, err
+			}
+			fileObjs, err := fn.ParseKubeObjects(in)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", file, err)
+			}
+			objs = append(objs, fileObjs...)
+		}
+	}
+	return objs, nil
+}
+
+// Run builds the ResourceContext from c, runs it with r and writes the outputs
+// and results to c.OutputDir, in the Format of the origin file. A summary of
+// the outputs and results is written to w. The resulting ResourceContext is
+// returned along with the error of r.
+//
+// Run doesn't remove the files of outputs that a previous run wrote and this
+// run didn't, see Watch.
+func Run(ctx context.Context, c *Config, r Runner, w io.Writer) (*fn.ResourceContext, error) {
+	resp, _, err := run(ctx, c, r, w)
+	return resp, err
+}
+
+// run runs the function like Run and returns the files the outputs were
+// written to. The files are nil if the outputs were not written.
+func run(ctx context.Context, c *Config, r Runner, w io.Writer) (*fn.ResourceContext, []string, error) {
+	rctx, f, err := c.resourceContext()
+	if err != nil {
+		return nil, nil, err
+	}
+	in, err := rctx.ToYAML()
+	if err != nil {
+		return nil, nil, err
+	}
+	out, runErr := r.Run(ctx, in)
+	if len(bytes.TrimSpace(out)) == 0 {
+		if runErr == nil {
+			runErr = fmt.Errorf("the function returned no ResourceContext")
+		}
+		return nil, nil, runErr
+	}
+	resp, err := fn.ParseResourceContext(out)
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to parse the returned ResourceContext: %w", err)
+	}
+	var files []string
+	if c.OutputDir != "" {
+		if files, err = WriteOutputsWithFormat(c.OutputDir, resp.Outputs, f); err != nil {
+			return resp, nil, err
+		}
+		if files == nil {
+			files = []string{}
+		}
+		if err := WriteResults(filepath.Join(c.OutputDir, ResultsFile), resp.Results); err != nil {
+			return resp, files, err
+		}
+	}
+	if err := WriteSummary(w, resp, files); err != nil {
+		return resp, files, err
+	}
+	return resp, files, runErr
+}
+
+// WriteOutputs writes every output to its own file in dir and returns the file
+// names. A file is named after the namespace, kind and name of the output.
+func WriteOutputs(dir string, outputs fn.KubeObjects) ([]string, error) {
+	return WriteOutputsWithFormat(dir, outputs, fn.Format{})
+}
+
+// WriteOutputsWithFormat writes the outputs like WriteOutputs, in the format f.
+func WriteOutputsWithFormat(dir string, outputs fn.KubeObjects, f fn.Format) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var files []string
+	written := map[string]bool{}
+	for _, output := range outputs {
+		name := FileName(output)
+		// outputs with the same file name get a suffix, skipping the names of
+		// the files already written
+		for n := 1; written[name]; n++ {
+			name = fmt.Sprintf("%s_%d.yaml", strings.TrimSuffix(FileName(output), ".yaml"), n)
+		}
+		written[name] = true
+		file := filepath.Join(dir, name)
+		if rel, err := filepath.Rel(dir, file); err != nil || rel != name {
+			return files, fmt.Errorf("output %s can't be written outside %s", output.ShortString(), dir)
+		}
+		b, err := f.Marshal(output)
+		if err != nil {
+			return files, err
+		}
+		if err := ioutil.WriteFile(file, b, 0o644); err != nil {
+			return files, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+// WriteResults writes results to file in yaml.
+func WriteResults(file string, results fn.Results) error {
+	obj, err := fn.NewFromTypedObject(struct {
+		Results fn.Results `json:"results"`
+	}{results})
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, []byte(obj.String()), 0o644)
+}
+
+// WriteSummary writes a human readable summary of the outputs and results of
+// rctx to w. files are the files the outputs were written to, if any.
+func WriteSummary(w io.Writer, rctx *fn.ResourceContext, files []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "outputs: %d\n", len(rctx.Outputs))
+	for i, output := range rctx.Outputs {
+		fmt.Fprintf(&b, "  %s", output.ShortString())
+		if i < len(files) {
+			fmt.Fprintf(&b, " -> %s", files[i])
+		}
+		b.WriteString("\n")
+	}
+	counts := map[fn.Severity]int{}
+	for _, result := range rctx.Results {
+		counts[result.Severity]++
+	}
+	fmt.Fprintf(&b, "results: %d (%d error, %d warning, %d info)\n",
+		len(rctx.Results), counts[fn.Error], counts[fn.Warning], len(rctx.Results)-counts[fn.Error]-counts[fn.Warning])
+	for _, result := range rctx.Results {
+		fmt.Fprintf(&b, "  %s\n", result)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// FileName returns the name of the file an output is written to. Path
+// separators in the namespace, kind and name are escaped, so the file is always
+// in the output directory.
+func FileName(obj *fn.KubeObject) string {
+	parts := []string{strings.ToLower(obj.GetKind()), obj.GetName()}
+	if ns := obj.GetNamespace(); ns != "" {
+		parts = append([]string{ns}, parts...)
+	}
+	for i, part := range parts {
+		parts[i] = internal.FileNameElement(part)
+	}
+	return strings.Join(parts, "_") + ".yaml"
+}
+
+func readObject(file string) (*fn.KubeObject, fn.Format, error) {
+	in, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, fn.Format{}, err
+	}
+	objs, f, err := fn.ParseKubeObjectsWithFormat(in)
+	if err != nil {
+		return nil, f, fmt.Errorf("%s: %w", file, err)
+	}
+	if len(objs) != 1 {
+		return nil, f, fmt.Errorf("%s: expected exactly one object, got %d", file, len(objs))
+	}
+	return objs[0], f, nil
+}
+
+func isManifest(file string) bool {
+	switch filepath.Ext(file) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
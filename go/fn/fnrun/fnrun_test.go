@@ -0,0 +1,242 @@
+package fnrun
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// testObject returns a KubeObject of kind with name in namespace.
+func testObject(t *testing.T, kind, namespace, name string) *fn.KubeObject {
+	t.Helper()
+	obj := fn.NewEmptyKubeObject()
+	obj.SetAPIVersion("v1")
+	obj.SetKind(kind)
+	obj.SetName(name)
+	if namespace != "" {
+		obj.SetNamespace(namespace)
+	}
+	return obj
+}
+
+// writeFile writes content to the file at path in dir, creating its directory.
+func writeFile(t *testing.T, dir, path, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(file, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestFileName(t *testing.T) {
+	tests := map[string]struct {
+		kind, namespace, name string
+		want                  string
+	}{
+		"cluster scoped": {kind: "ConfigMap", name: "cm", want: "configmap_cm.yaml"},
+		"namespaced":     {kind: "ConfigMap", namespace: "ns", name: "cm", want: "ns_configmap_cm.yaml"},
+		"slash":          {kind: "ConfigMap", name: "a/b", want: "configmap_a_b.yaml"},
+		"backslash":      {kind: "ConfigMap", name: `a\b`, want: "configmap_a_b.yaml"},
+		"parent name":    {kind: "ConfigMap", name: "..", want: "configmap__...yaml"},
+		"parent path":    {kind: "ConfigMap", namespace: "..", name: "../../etc/passwd", want: "_.._configmap_.._.._etc_passwd.yaml"},
+		"current name":   {kind: "ConfigMap", name: ".", want: "configmap__..yaml"},
+		"control chars":  {kind: "ConfigMap", name: "a\nb\x00", want: "configmap_a_b_.yaml"},
+		"slash in kind":  {kind: "a/B", name: "cm", want: "a_b_cm.yaml"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := FileName(testObject(t, tc.kind, tc.namespace, tc.name)); got != tc.want {
+				t.Errorf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestWriteOutputsWithFormat(t *testing.T) {
+	tests := map[string]struct {
+		outputs func(t *testing.T) fn.KubeObjects
+		format  fn.Format
+		want    []string
+		content string
+	}{
+		"names": {
+			outputs: func(t *testing.T) fn.KubeObjects {
+				return fn.KubeObjects{
+					testObject(t, "ConfigMap", "", "a/b"),
+					testObject(t, "ConfigMap", "..", ".."),
+					testObject(t, "Secret", "ns", "../../s"),
+				}
+			},
+			want:    []string{"configmap_a_b.yaml", "_.._configmap__...yaml", "ns_secret_.._.._s.yaml"},
+			content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a/b\n",
+		},
+		"same file name": {
+			outputs: func(t *testing.T) fn.KubeObjects {
+				return fn.KubeObjects{
+					testObject(t, "ConfigMap", "", "a/b"),
+					testObject(t, "ConfigMap", "", "a_b"),
+					// the name of the suffixed file is taken
+					testObject(t, "ConfigMap", "", "a_b_1"),
+					testObject(t, "ConfigMap", "", "a\\b"),
+				}
+			},
+			want:    []string{"configmap_a_b.yaml", "configmap_a_b_1.yaml", "configmap_a_b_1_1.yaml", "configmap_a_b_2.yaml"},
+			content: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: a/b\n",
+		},
+		"format": {
+			outputs: func(t *testing.T) fn.KubeObjects {
+				obj := testObject(t, "ConfigMap", "", "cm")
+				if err := obj.SetNestedStringSlice([]string{"x"}, "data", "items"); err != nil {
+					t.Fatal(err)
+				}
+				return fn.KubeObjects{obj}
+			},
+			format:  fn.DetectFormat([]byte("---\na:\n  - b\n")),
+			want:    []string{"configmap_cm.yaml"},
+			content: "---\napiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata:\n  items:\n    - x\n",
+		},
+		"none": {
+			outputs: func(t *testing.T) fn.KubeObjects { return nil },
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "out")
+			files, err := WriteOutputsWithFormat(dir, tc.outputs(t), tc.format)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, file := range files {
+				if filepath.Dir(file) != dir {
+					t.Errorf("expected %s to be written in %s", file, dir)
+				}
+				names = append(names, filepath.Base(file))
+			}
+			if !reflect.DeepEqual(names, tc.want) {
+				t.Errorf("expected files %q, got %q", tc.want, names)
+			}
+			entries, err := os.ReadDir(dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != len(tc.want) {
+				t.Errorf("expected %d files in %s, got %v", len(tc.want), dir, entries)
+			}
+			if len(files) > 0 {
+				b, err := os.ReadFile(files[0])
+				if err != nil {
+					t.Fatal(err)
+				}
+				if string(b) != tc.content {
+					t.Errorf("expected the content\n%s\ngot\n%s", tc.content, b)
+				}
+			}
+		})
+	}
+}
+
+func TestReadObjects(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "items/b.yaml", "apiVersion: v1\nkind: Node\nmetadata:\n  name: b\n")
+	writeFile(t, dir, "items/a.yml", "apiVersion: v1\nkind: Node\nmetadata:\n  name: a1\n---\napiVersion: v1\nkind: Node\nmetadata:\n  name: a2\n")
+	writeFile(t, dir, "items/nested/c.json", `{"apiVersion": "v1", "kind": "Node", "metadata": {"name": "c"}}`)
+	writeFile(t, dir, "items/README.md", "not a manifest")
+	explicit := writeFile(t, dir, "node.txt", "apiVersion: v1\nkind: Node\nmetadata:\n  name: d\n")
+	invalid := writeFile(t, dir, "invalid/x.yaml", "- a\n- b\n")
+
+	objs, err := ReadObjects(filepath.Join(dir, "items"), explicit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, obj := range objs {
+		names = append(names, obj.GetName())
+	}
+	if want := []string{"a1", "a2", "b", "c", "d"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected %v, got %v", want, names)
+	}
+
+	if _, err := ReadObjects(filepath.Join(dir, "missing")); err == nil {
+		t.Error("expected an error for a missing path")
+	}
+	if _, err := ReadObjects(invalid); err == nil || !strings.HasPrefix(err.Error(), invalid+": ") {
+		t.Errorf("expected an error naming %s, got %v", invalid, err)
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	rctx := &fn.ResourceContext{
+		Outputs: fn.KubeObjects{testObject(t, "ConfigMap", "ns", "cm"), testObject(t, "Secret", "", "s")},
+		Results: fn.Results{
+			fn.GeneralResult("invalid", fn.Error),
+			fn.GeneralResult("deprecated", fn.Warning),
+			fn.GeneralResult("rendered", fn.Info),
+			fn.GeneralResult("done", ""),
+		},
+	}
+	var b bytes.Buffer
+	if err := WriteSummary(&b, rctx, []string{"out/ns_configmap_cm.yaml"}); err != nil {
+		t.Fatal(err)
+	}
+	want := `outputs: 2
+  Resource(apiVersion=v1, kind=ConfigMap, namespace=ns, name=cm) -> out/ns_configmap_cm.yaml
+  Resource(apiVersion=v1, kind=Secret, namespace=, name=s)
+results: 4 (1 error, 1 warning, 2 info)
+  [error]: invalid
+  [warning]: deprecated
+  [info]: rendered
+  [info]: done
+`
+	if b.String() != want {
+		t.Errorf("expected\n%s\ngot\n%s", want, b.String())
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	c := &Config{
+		Origin:    writeFile(t, dir, "app.yaml", "---\napiVersion: app.yndd.io/v1alpha1\nkind: App\nmetadata:\n  name: app1\n"),
+		Items:     []string{writeFile(t, dir, "items/node.yaml", "apiVersion: v1\nkind: Node\nmetadata:\n  name: n\n")},
+		OutputDir: filepath.Join(dir, "out"),
+	}
+	p := fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		for _, item := range rctx.Input.Items {
+			rctx.Outputs = append(rctx.Outputs, testObject(t, "ConfigMap", "", rctx.Input.Origin.GetName()+"/"+item.GetName()))
+		}
+		rctx.LogResult(fn.GeneralResult("rendered", fn.Info))
+		return true, nil
+	})
+	var summary bytes.Buffer
+	resp, err := Run(context.Background(), c, InProcess(p), &summary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Outputs) != 1 {
+		t.Fatalf("expected an output, got %d", len(resp.Outputs))
+	}
+	output := filepath.Join(c.OutputDir, "configmap_app1_n.yaml")
+	b, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(b), "---\n") {
+		t.Errorf("expected the output in the format of the origin\n%s", b)
+	}
+	if b, err := os.ReadFile(filepath.Join(c.OutputDir, ResultsFile)); err != nil || !strings.Contains(string(b), "message: rendered") {
+		t.Errorf("expected the results file, got %q, %v", b, err)
+	}
+	if !strings.Contains(summary.String(), "-> "+output+"\n") {
+		t.Errorf("expected the summary to tell the file of the output\n%s", summary.String())
+	}
+}
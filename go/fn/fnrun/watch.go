@@ -0,0 +1,237 @@
+package fnrun
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// DefaultWatchInterval is the interval the watched files are polled at.
+const DefaultWatchInterval = 500 * time.Millisecond
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	// Interval is the interval the files are polled at. It defaults to
+	// DefaultWatchInterval.
+	Interval time.Duration
+	// Paths are watched in addition to the files of the Config, e.g. the
+	// function binary or the directory holding its source.
+	Paths []string
+}
+
+// Watch runs the function like Run, and again whenever the origin, target,
+// items, functionConfig or one of the additional paths changes, until ctx is
+// done. After every run but the first, the difference between the outputs of
+// the run and of the previous run is written to w, and the files of the outputs
+// of the previous run that are gone are removed from c.OutputDir. A failed run
+// is reported to w and doesn't stop the watch.
+func Watch(ctx context.Context, c *Config, r Runner, w io.Writer, opts WatchOptions) error {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+	paths := append([]string{c.Origin, c.Target, c.FunctionConfig}, c.Items...)
+	paths = append(paths, opts.Paths...)
+
+	var previous fn.KubeObjects
+	var written []string
+	first := true
+	state := snapshot(paths)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		fmt.Fprintf(w, "--- run at %s\n", time.Now().Format(time.RFC3339))
+		resp, files, err := run(ctx, c, r, w)
+		if err != nil {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+		if files != nil {
+			removeStale(written, files, w)
+			written = files
+		}
+		if resp != nil {
+			resp.Sort()
+			if !first {
+				fmt.Fprint(w, Diff(previous, resp.Outputs))
+			}
+			previous, first = resp.Outputs, false
+		}
+		fmt.Fprintf(w, "--- watching for changes\n")
+
+		for changed := false; !changed; {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-ticker.C:
+			}
+			next := snapshot(paths)
+			changed = !next.equal(state)
+			state = next
+		}
+	}
+}
+
+// removeStale removes the files of a previous run that the current run didn't
+// write.
+func removeStale(previous, current []string, w io.Writer) {
+	keep := map[string]bool{}
+	for _, file := range current {
+		keep[file] = true
+	}
+	for _, file := range previous {
+		if keep[file] {
+			continue
+		}
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(w, "error: %v\n", err)
+		}
+	}
+}
+
+// Diff returns a semantic diff of the outputs of two runs. Outputs are matched
+// on apiVersion, kind, namespace and name, and the changed fields of the
+// outputs found in both runs are listed by their path.
+func Diff(previous, current fn.KubeObjects) string {
+	prev := map[string]*fn.KubeObject{}
+	for _, obj := range previous {
+		prev[obj.ShortString()] = obj
+	}
+	var b strings.Builder
+	seen := map[string]bool{}
+	for _, obj := range current {
+		key := obj.ShortString()
+		seen[key] = true
+		old, ok := prev[key]
+		if !ok {
+			fmt.Fprintf(&b, "+ %s\n", key)
+			continue
+		}
+		changes := diffFields(flatten(old), flatten(obj))
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "~ %s\n", key)
+		for _, change := range changes {
+			fmt.Fprintf(&b, "    %s\n", change)
+		}
+	}
+	for _, obj := range previous {
+		if key := obj.ShortString(); !seen[key] {
+			fmt.Fprintf(&b, "- %s\n", key)
+		}
+	}
+	if b.Len() == 0 {
+		return "outputs unchanged\n"
+	}
+	return b.String()
+}
+
+func diffFields(old, new map[string]string) []string {
+	var paths []string
+	for path := range old {
+		paths = append(paths, path)
+	}
+	for path := range new {
+		if _, ok := old[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var changes []string
+	for _, path := range paths {
+		o, inOld := old[path]
+		n, inNew := new[path]
+		switch {
+		case !inOld:
+			changes = append(changes, fmt.Sprintf("+ %s: %s", path, n))
+		case !inNew:
+			changes = append(changes, fmt.Sprintf("- %s: %s", path, o))
+		case o != n:
+			changes = append(changes, fmt.Sprintf("~ %s: %s -> %s", path, o, n))
+		}
+	}
+	return changes
+}
+
+// flatten maps the path of every scalar field of obj to its value in json.
+// Empty maps and lists are fields too, so adding or removing them is a change.
+func flatten(obj *fn.KubeObject) map[string]string {
+	var v interface{}
+	fields := map[string]string{}
+	if err := obj.As(&v); err != nil {
+		return fields
+	}
+	flattenValue("", v, fields)
+	return fields
+}
+
+func flattenValue(path string, v interface{}, fields map[string]string) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 {
+			fields[path] = "{}"
+		}
+		for k, val := range v {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			flattenValue(p, val, fields)
+		}
+	case []interface{}:
+		if len(v) == 0 {
+			fields[path] = "[]"
+		}
+		for i, val := range v {
+			flattenValue(fmt.Sprintf("%s[%d]", path, i), val, fields)
+		}
+	default:
+		b, _ := json.Marshal(v)
+		fields[path] = string(b)
+	}
+}
+
+// fileState holds the modification time and size of the watched files.
+type fileState map[string]string
+
+func snapshot(paths []string) fileState {
+	state := fileState{}
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		_ = filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				// a missing file is a state too, e.g. while an editor replaces it
+				state[file] = "missing"
+				return nil
+			}
+			if !info.IsDir() {
+				state[file] = fmt.Sprintf("%d/%d", info.ModTime().UnixNano(), info.Size())
+			}
+			return nil
+		})
+	}
+	return state
+}
+
+func (s fileState) equal(o fileState) bool {
+	if len(s) != len(o) {
+		return false
+	}
+	for k, v := range s {
+		if o[k] != v {
+			return false
+		}
+	}
+	return true
+}
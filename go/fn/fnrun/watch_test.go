@@ -0,0 +1,75 @@
+package fnrun
+
+import (
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+func TestDiff(t *testing.T) {
+	parse := func(t *testing.T, in string) fn.KubeObjects {
+		if in == "" {
+			return nil
+		}
+		objs, err := fn.ParseKubeObjects([]byte(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return objs
+	}
+	const (
+		cm    = "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"
+		cmKey = "Resource(apiVersion=v1, kind=ConfigMap, namespace=, name=cm)"
+	)
+	tests := map[string]struct {
+		previous, current string
+		want              string
+	}{
+		"unchanged": {
+			previous: cm + "data: {a: b}\n",
+			current:  cm + "data: {a: b}\n",
+			want:     "outputs unchanged\n",
+		},
+		"added and removed": {
+			previous: cm,
+			current:  "apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\n",
+			want:     "+ Resource(apiVersion=v1, kind=Secret, namespace=, name=s)\n- " + cmKey + "\n",
+		},
+		"changed fields": {
+			previous: cm + "data: {a: b, c: d}\nitems: [x]\n",
+			current:  cm + "data: {a: c, e: f}\nitems: [x, y]\n",
+			want: "~ " + cmKey + "\n" +
+				"    ~ data.a: \"b\" -> \"c\"\n" +
+				"    - data.c: \"d\"\n" +
+				"    + data.e: \"f\"\n" +
+				"    + items[1]: \"y\"\n",
+		},
+		"empty map removed": {
+			previous: cm + "spec: {}\n",
+			current:  cm,
+			want:     "~ " + cmKey + "\n    - spec: {}\n",
+		},
+		"empty list added": {
+			previous: cm,
+			current:  cm + "items: []\n",
+			want:     "~ " + cmKey + "\n    + items: []\n",
+		},
+		"empty map filled": {
+			previous: cm + "spec: {}\n",
+			current:  cm + "spec: {a: 1}\n",
+			want:     "~ " + cmKey + "\n    - spec: {}\n    + spec.a: 1\n",
+		},
+		"list emptied": {
+			previous: cm + "items: [x]\n",
+			current:  cm + "items: []\n",
+			want:     "~ " + cmKey + "\n    + items: []\n    - items[0]: \"x\"\n",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := Diff(parse(t, tc.previous), parse(t, tc.current)); got != tc.want {
+				t.Errorf("expected\n%s\ngot\n%s", tc.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package fn
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// Format is the layout of yaml documents beyond their content, so objects can
+// be written back the way they were read. Key order, quoting styles and
+// comments are kept by the objects themselves, as long as they are edited in
+// place or with MergeNestedField: SetNestedField with a typed value writes the
+// keys of the value in sorted order. WithPreserveFormat makes a run respond in
+// the Format of its input.
+type Format struct {
+	// SeqIndent is the indentation of block sequences, yaml.CompactSequenceStyle
+	// or yaml.WideSequenceStyle. It defaults to compact.
+	SeqIndent yaml.SequenceIndentStyle
+	// DocumentStart starts the first document with a "---" separator too.
+	DocumentStart bool
+}
+
+// DetectFormat returns the Format of the yaml documents in b.
+func DetectFormat(b []byte) Format {
+	return Format{
+		SeqIndent:     yaml.SequenceIndentStyle(yaml.DeriveSeqIndentStyle(string(b))),
+		DocumentStart: hasDocumentStart(b),
+	}
+}
+
+// ParseKubeObjectsWithFormat parses b like ParseKubeObjects and returns its
+// Format. The objects themselves don't record the Format, so objects built from
+// them, e.g. outputs built from an origin, are written back in the Format of
+// their source by passing it on to Marshal.
+func ParseKubeObjectsWithFormat(b []byte) (KubeObjects, Format, error) {
+	f := DetectFormat(b)
+	objs, err := ParseKubeObjects(b)
+	if err != nil {
+		return nil, f, err
+	}
+	return objs, f, nil
+}
+
+// Marshal writes objs as yaml documents in the format.
+func (f Format) Marshal(objs ...*KubeObject) ([]byte, error) {
+	var b bytes.Buffer
+	for i, obj := range objs {
+		if i > 0 || f.DocumentStart {
+			b.WriteString("---\n")
+		}
+		if err := yaml.NewEncoderWithOptions(&b, &yaml.EncoderOptions{SeqIndent: f.SeqIndent}).Encode(obj.obj.Node()); err != nil {
+			return nil, err
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// hasDocumentStart returns whether the first document in b starts with an
+// explicit "---" separator.
+func hasDocumentStart(b []byte) bool {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		return line == "---" || strings.HasPrefix(line, "--- ")
+	}
+	return false
+}
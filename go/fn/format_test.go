@@ -0,0 +1,91 @@
+package fn
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// formattedInput is laid out unlike ToYAML: unsorted keys and items, wide
+// sequences, flow style, quoting, comments and a document start.
+const formattedInput = `---
+# the request of the app controller
+kind: ResourceContext
+apiVersion: app.yndd.io/v1
+input:
+  origin:
+    kind: App # the origin
+    apiVersion: app.yndd.io/v1alpha1
+    metadata:
+      name: 'app1'
+      labels: {tier: "1", team: net}
+    spec:
+      interfaces:
+        - name: eth1
+          mtu: 1500
+        # the management interface
+        - name: eth0
+  items:
+    - kind: Node
+      apiVersion: v1
+      metadata:
+        name: b
+    - kind: Node
+      apiVersion: v1
+      metadata:
+        name: a
+`
+
+func TestRunContextPreserveFormat(t *testing.T) {
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		return true, nil
+	})
+
+	out, err := RunContext(context.Background(), p, []byte(formattedInput), WithPreserveFormat())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != formattedInput {
+		t.Errorf("expected the input back unchanged, got\n%s", out)
+	}
+
+	out, err = RunContext(context.Background(), p, []byte(formattedInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) == formattedInput || !strings.HasPrefix(string(out), "apiVersion: app.yndd.io/v1\nkind: ResourceContext\n") {
+		t.Errorf("expected the input to be sorted without WithPreserveFormat, got\n%s", out)
+	}
+}
+
+func TestToYAMLWithFormatChanges(t *testing.T) {
+	rctx, err := ParseResourceContext([]byte(formattedInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rctx.Input.Origin.SetNestedString("app2", "metadata", "name"); err != nil {
+		t.Fatal(err)
+	}
+	rctx.Input.Items = rctx.Input.Items[1:]
+	rctx.Outputs = append(rctx.Outputs, testOutput(t))
+
+	out, err := rctx.ToYAMLWithFormat(DetectFormat([]byte(formattedInput)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := strings.Replace(formattedInput, "name: 'app1'", "name: 'app2'", 1)
+	want = strings.Replace(want, `    - kind: Node
+      apiVersion: v1
+      metadata:
+        name: b
+`, "", 1)
+	want += `outputs:
+  - apiVersion: v1
+    kind: ConfigMap
+    metadata:
+      name: cm
+`
+	if string(out) != want {
+		t.Errorf("unexpected output\n%s\nexpected\n%s", out, want)
+	}
+}
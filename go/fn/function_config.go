@@ -0,0 +1,160 @@
+package fn
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Defaulter is implemented by function config types that set defaults for the
+// fields the functionConfig leaves empty.
+type Defaulter interface {
+	Default() error
+}
+
+// Validator is implemented by function config types that validate themselves
+// after defaulting.
+type Validator interface {
+	Validate() error
+}
+
+// GetFunctionConfig decodes Input.FunctionConfig into ptr, applies its
+// defaults and validates it. A ConfigMap functionConfig is decoded from its
+// `data`, with each entry converted to the type of the field whose json name
+// matches the key; any other functionConfig is decoded as a whole. It returns
+// ErrMissingFnConfig if the ResourceContext has no functionConfig.
+func (rctx *ResourceContext) GetFunctionConfig(ptr interface{}) error {
+	if ptr == nil || reflect.ValueOf(ptr).Kind() != reflect.Ptr {
+		return fmt.Errorf("ptr must be a pointer to an object")
+	}
+	if rctx.Input == nil || rctx.Input.FunctionConfig == nil {
+		return ErrMissingFnConfig{}
+	}
+	fnConfig := rctx.Input.FunctionConfig
+	if fnConfig.IsGVK("", "v1", "ConfigMap") {
+		data, _, err := fnConfig.NestedStringMap("data")
+		if err != nil {
+			return fmt.Errorf("unable to get the data of the functionConfig: %w", err)
+		}
+		if err := decodeStringData(data, ptr); err != nil {
+			return fmt.Errorf("unable to decode the functionConfig data to %T: %w", ptr, err)
+		}
+	} else if err := fnConfig.As(ptr); err != nil {
+		return err
+	}
+	if d, ok := ptr.(Defaulter); ok {
+		if err := d.Default(); err != nil {
+			return fmt.Errorf("unable to default the functionConfig: %w", err)
+		}
+	}
+	if v, ok := ptr.(Validator); ok {
+		if err := v.Validate(); err != nil {
+			return fmt.Errorf("invalid functionConfig: %w", err)
+		}
+	}
+	return nil
+}
+
+// GetFunctionConfigData returns the `data` of a ConfigMap functionConfig. It
+// returns ErrMissingFnConfig if the ResourceContext has no functionConfig.
+func (rctx *ResourceContext) GetFunctionConfigData() (map[string]string, error) {
+	if rctx.Input == nil || rctx.Input.FunctionConfig == nil {
+		return nil, ErrMissingFnConfig{}
+	}
+	data, _, err := rctx.Input.FunctionConfig.NestedStringMap("data")
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the data of the functionConfig: %w", err)
+	}
+	return data, nil
+}
+
+// decodeStringData decodes ConfigMap data into ptr, which points to a
+// map[string]string or a struct.
+func decodeStringData(data map[string]string, ptr interface{}) error {
+	v := reflect.ValueOf(ptr).Elem()
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String || v.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported map type %s", v.Type())
+		}
+		if v.IsNil() {
+			v.Set(reflect.MakeMap(v.Type()))
+		}
+		for k, val := range data {
+			v.SetMapIndex(reflect.ValueOf(k), reflect.ValueOf(val))
+		}
+		return nil
+	case reflect.Struct:
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		name := field.Name
+		if tag, ok := field.Tag.Lookup("json"); ok {
+			tagName := strings.Split(tag, ",")[0]
+			if tagName == "-" {
+				continue
+			}
+			if tagName != "" {
+				name = tagName
+			}
+		}
+		s, ok := data[name]
+		if !ok {
+			continue
+		}
+		if err := setFromString(v.Field(i), s); err != nil {
+			return fmt.Errorf("field %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setFromString sets v from the string s. Types other than strings, bools and
+// numbers are decoded from s as json.
+func setFromString(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Ptr:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setFromString(v.Elem(), s)
+	default:
+		return json.Unmarshal([]byte(s), v.Addr().Interface())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package fn
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Wildcard matches any group, version or kind in a GroupVersionKind.
+const Wildcard = "*"
+
+// GroupVersionKind identifies a kind of KubeObject, e.g. in a Router route or a
+// FunctionSpec. Any of its fields can be Wildcard. As in KubeObject.IsGVK, the
+// core group is the empty group, an empty group and version match any
+// apiVersion and an empty kind matches any kind.
+type GroupVersionKind struct {
+	Group   string `yaml:"group,omitempty" json:"group,omitempty"`
+	Version string `yaml:"version,omitempty" json:"version,omitempty"`
+	Kind    string `yaml:"kind,omitempty" json:"kind,omitempty"`
+}
+
+// GVKOf returns the GroupVersionKind of obj.
+func GVKOf(obj *KubeObject) GroupVersionKind {
+	group, version := "", obj.GetAPIVersion()
+	if i := strings.Index(version, "/"); i >= 0 {
+		group, version = version[:i], version[i+1:]
+	}
+	return GroupVersionKind{Group: group, Version: version, Kind: obj.GetKind()}
+}
+
+// Matches tells whether obj is of this kind.
+func (gvk GroupVersionKind) Matches(obj *KubeObject) bool {
+	objGVK := GVKOf(obj)
+	anyAPIVersion := gvk.Group == "" && gvk.Version == ""
+	if !anyAPIVersion && !(matchesPart(gvk.Group, objGVK.Group) && matchesPart(gvk.Version, objGVK.Version)) {
+		return false
+	}
+	return gvk.Kind == "" || matchesPart(gvk.Kind, objGVK.Kind)
+}
+
+// GroupVersionKinds is a list of kinds, e.g. the kinds a FunctionSpec accepts.
+type GroupVersionKinds []GroupVersionKind
+
+// Matches tells whether obj is of one of the kinds.
+func (gvks GroupVersionKinds) Matches(obj *KubeObject) bool {
+	for _, gvk := range gvks {
+		if gvk.Matches(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+func (gvk GroupVersionKind) String() string {
+	if gvk.Group == "" {
+		return fmt.Sprintf("%s/%s", gvk.Version, gvk.Kind)
+	}
+	return fmt.Sprintf("%s/%s/%s", gvk.Group, gvk.Version, gvk.Kind)
+}
+
+// wildcards returns the number of parts that match any value, for ranking the
+// kinds an object matches. An empty group and version count as two and an
+// empty kind as one, as in Matches.
+func (gvk GroupVersionKind) wildcards() int {
+	n := 0
+	for _, s := range []string{gvk.Group, gvk.Version, gvk.Kind} {
+		if s == Wildcard {
+			n++
+		}
+	}
+	if gvk.Group == "" && gvk.Version == "" {
+		n += 2
+	}
+	if gvk.Kind == "" {
+		n++
+	}
+	return n
+}
+
+func matchesPart(pattern, value string) bool {
+	return pattern == Wildcard || pattern == value
+}
@@ -0,0 +1,95 @@
+package fn
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testObject(t *testing.T, apiVersion, kind string) *KubeObject {
+	t.Helper()
+	obj, err := ParseKubeObject([]byte(fmt.Sprintf("apiVersion: %s\nkind: %s\nmetadata:\n  name: obj\n", apiVersion, kind)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestGroupVersionKindMatches(t *testing.T) {
+	tests := []struct {
+		gvk        GroupVersionKind
+		apiVersion string
+		kind       string
+		want       bool
+	}{
+		{GroupVersionKind{"app.yndd.io", "v1alpha1", "App"}, "app.yndd.io/v1alpha1", "App", true},
+		{GroupVersionKind{"app.yndd.io", "v1alpha1", "App"}, "app.yndd.io/v1", "App", false},
+		{GroupVersionKind{"", "v1", "ConfigMap"}, "v1", "ConfigMap", true},
+		{GroupVersionKind{"", "v1", "ConfigMap"}, "apps/v1", "ConfigMap", false},
+		{GroupVersionKind{"", "", "App"}, "app.yndd.io/v1alpha1", "App", true},
+		{GroupVersionKind{"", "", "App"}, "app.yndd.io/v1alpha1", "Node", false},
+		{GroupVersionKind{"app.yndd.io", "v1alpha1", ""}, "app.yndd.io/v1alpha1", "Node", true},
+		{GroupVersionKind{"app.yndd.io", "v1alpha1", ""}, "app.yndd.io/v1", "Node", false},
+		{GroupVersionKind{"", "", "*"}, "app.yndd.io/v1alpha1", "App", true},
+		{GroupVersionKind{"", "", "*"}, "v1", "ConfigMap", true},
+		{GroupVersionKind{"", "", ""}, "apps/v1", "Deployment", true},
+		{GroupVersionKind{"*", "*", ""}, "apps/v1", "Deployment", true},
+		{GroupVersionKind{"*", "", "App"}, "app.yndd.io/v1alpha1", "App", false},
+		{GroupVersionKind{"", "*", "ConfigMap"}, "v1", "ConfigMap", true},
+		{GroupVersionKind{"", "*", "ConfigMap"}, "apps/v1", "ConfigMap", false},
+		{GroupVersionKind{"app.yndd.io", "*", "App"}, "app.yndd.io/v1", "App", true},
+		{GroupVersionKind{"app.yndd.io", "*", "*"}, "other.yndd.io/v1", "App", false},
+	}
+	for _, tc := range tests {
+		t.Run(fmt.Sprintf("%v %s %s", tc.gvk, tc.apiVersion, tc.kind), func(t *testing.T) {
+			if got := tc.gvk.Matches(testObject(t, tc.apiVersion, tc.kind)); got != tc.want {
+				t.Errorf("expected %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRouterRanking(t *testing.T) {
+	routes := []GroupVersionKind{
+		{"", "", "*"},
+		{"*", "*", "*"},
+		{"", "", "App"},
+		{"app.yndd.io", "*", "App"},
+		{"app.yndd.io", "v1alpha1", ""},
+		{"app.yndd.io", "v1alpha1", "App"},
+		{"", "*", "ConfigMap"},
+	}
+	r := NewRouter()
+	for _, gvk := range routes {
+		gvk := gvk
+		r.HandleFunc(gvk.Group, gvk.Version, gvk.Kind, func(rc *ResourceContext) (bool, error) {
+			rc.Results = append(rc.Results, GeneralResult(fmt.Sprint(gvk), Info))
+			return true, nil
+		})
+	}
+
+	tests := []struct {
+		apiVersion string
+		kind       string
+		want       GroupVersionKind
+	}{
+		{"app.yndd.io/v1alpha1", "App", GroupVersionKind{"app.yndd.io", "v1alpha1", "App"}},
+		{"app.yndd.io/v1", "App", GroupVersionKind{"app.yndd.io", "*", "App"}},
+		{"app.yndd.io/v1alpha1", "Node", GroupVersionKind{"app.yndd.io", "v1alpha1", ""}},
+		{"other.yndd.io/v1", "App", GroupVersionKind{"", "", "App"}},
+		{"v1", "ConfigMap", GroupVersionKind{"", "*", "ConfigMap"}},
+		// "*" for every part and ("", "", "*") both count three wildcards,
+		// the first registered wins
+		{"apps/v1", "Deployment", GroupVersionKind{"", "", "*"}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.apiVersion+" "+tc.kind, func(t *testing.T) {
+			rc := &ResourceContext{Input: &ResourceContextInputs{Origin: testObject(t, tc.apiVersion, tc.kind)}}
+			if _, err := r.Process(rc); err != nil {
+				t.Fatal(err)
+			}
+			if len(rc.Results) != 1 || rc.Results[0].Message != fmt.Sprint(tc.want) {
+				t.Errorf("expected route %v, got %v", tc.want, rc.Results)
+			}
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// LimitWriter buffers up to Limit bytes. When more is written, it calls
+// Exceeded and fails the write; without Exceeded, what exceeds the limit is
+// dropped instead. A Limit of zero or less doesn't bound the buffer.
+type LimitWriter struct {
+	Limit    int64
+	Exceeded func()
+
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (w *LimitWriter) Write(p []byte) (int, error) {
+	if w.Limit > 0 && int64(w.buf.Len()+len(p)) > w.Limit {
+		if w.Exceeded == nil {
+			// drop what exceeds the limit
+			if n := w.Limit - int64(w.buf.Len()); n > 0 {
+				w.buf.Write(p[:n])
+			}
+			return len(p), nil
+		}
+		w.overflow = true
+		w.Exceeded()
+		return 0, fmt.Errorf("output exceeds %d bytes", w.Limit)
+	}
+	return w.buf.Write(p)
+}
+
+// Bytes returns the bytes written so far.
+func (w *LimitWriter) Bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// Overflow returns whether a write failed because it exceeded the limit.
+func (w *LimitWriter) Overflow() bool {
+	return w.overflow
+}
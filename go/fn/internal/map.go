@@ -127,6 +127,11 @@ func (o *MapVariant) setYAMLNode(key string, node *yaml.Node) {
 			children[i+1].FootComment = oldNode.FootComment
 			children[i+1].HeadComment = oldNode.HeadComment
 			children[i+1].LineComment = oldNode.LineComment
+			// a scalar replaced by one of the same type keeps its quoting
+			if node.Kind == yaml.ScalarNode && oldNode.Kind == yaml.ScalarNode &&
+				node.Tag == oldNode.Tag && node.Style == 0 {
+				node.Style = oldNode.Style
+			}
 			return
 		}
 	}
@@ -181,6 +186,18 @@ func (o *MapVariant) sortFields() error {
 }
 
 func sortFields(ynode *yaml.Node) error {
+	switch ynode.Kind {
+	case yaml.MappingNode:
+	case yaml.SequenceNode:
+		for _, elem := range ynode.Content {
+			if err := sortFields(elem); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return nil
+	}
 	pairs, err := ynodeToYamlKeyValuePairs(ynode)
 	if err != nil {
 		return fmt.Errorf("unable to sort fields in yaml: %w", err)
@@ -2,6 +2,8 @@ package internal
 
 import (
 	"fmt"
+
+	"sigs.k8s.io/kustomize/kyaml/yaml"
 )
 
 func (o *MapVariant) GetNestedValue(fields ...string) (variant, bool, error) {
@@ -54,6 +56,30 @@ func (o *MapVariant) GetNestedMap(fields ...string) (*MapVariant, bool, error) {
 	return mv, found, err
 }
 
+// GetNestedEntry returns the key and the value node of the field located by
+// fields. With no fields, the key is nil and the value is the node of o.
+func (o *MapVariant) GetNestedEntry(fields ...string) (*yaml.Node, *yaml.Node, bool, error) {
+	if len(fields) == 0 {
+		return nil, o.node, true, nil
+	}
+	parent := o
+	if len(fields) > 1 {
+		var found bool
+		var err error
+		parent, found, err = o.GetNestedMap(fields[:len(fields)-1]...)
+		if err != nil || !found {
+			return nil, nil, found, err
+		}
+	}
+	children := parent.node.Content
+	for i := 0; i+1 < len(children); i += 2 {
+		if k, ok := asString(children[i]); ok && k == fields[len(fields)-1] {
+			return children[i], children[i+1], true, nil
+		}
+	}
+	return nil, nil, false, nil
+}
+
 func (o *MapVariant) SetNestedMap(m *MapVariant, fields ...string) error {
 	return o.SetNestedValue(m, fields...)
 }
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// MergeNode merges src into dst in place: dst takes the values of src, but the
+// nodes that remain keep their key order, styles and comments. Keys of dst that
+// are not in src are removed, and keys of src that are not in dst are appended
+// in the order of src.
+func MergeNode(dst, src *yaml.Node) {
+	if src.Kind == yaml.AliasNode {
+		src = src.Alias
+	}
+	if dst.Kind != src.Kind {
+		replaceNode(dst, src)
+		return
+	}
+	switch dst.Kind {
+	case yaml.DocumentNode:
+		if len(dst.Content) == 1 && len(src.Content) == 1 {
+			MergeNode(dst.Content[0], src.Content[0])
+			return
+		}
+		replaceNode(dst, src)
+	case yaml.MappingNode:
+		srcValues := map[string]*yaml.Node{}
+		var srcKeys []*yaml.Node
+		for i := 0; i+1 < len(src.Content); i += 2 {
+			srcKeys = append(srcKeys, src.Content[i])
+			srcValues[src.Content[i].Value] = src.Content[i+1]
+		}
+		var content []*yaml.Node
+		merged := map[string]bool{}
+		for i := 0; i+1 < len(dst.Content); i += 2 {
+			key, value := dst.Content[i], dst.Content[i+1]
+			srcValue, ok := srcValues[key.Value]
+			if !ok || merged[key.Value] {
+				continue
+			}
+			MergeNode(value, srcValue)
+			merged[key.Value] = true
+			content = append(content, key, value)
+		}
+		for _, key := range srcKeys {
+			if !merged[key.Value] {
+				merged[key.Value] = true
+				content = append(content, key, srcValues[key.Value])
+			}
+		}
+		dst.Content = content
+	case yaml.SequenceNode:
+		for i, elem := range src.Content {
+			if i < len(dst.Content) {
+				MergeNode(dst.Content[i], elem)
+			} else {
+				dst.Content = append(dst.Content, elem)
+			}
+		}
+		if len(dst.Content) > len(src.Content) {
+			dst.Content = dst.Content[:len(src.Content)]
+		}
+	case yaml.ScalarNode:
+		if dst.Value == src.Value && dst.ShortTag() == src.ShortTag() {
+			return
+		}
+		// a quoted or block style only fits strings
+		if src.ShortTag() != yaml.NodeTagString || dst.ShortTag() != yaml.NodeTagString {
+			dst.Style = src.Style
+		}
+		dst.Tag = src.Tag
+		dst.Value = src.Value
+	default:
+		replaceNode(dst, src)
+	}
+}
+
+// replaceNode replaces dst with src, keeping the comments of dst.
+func replaceNode(dst, src *yaml.Node) {
+	head, line, foot := dst.HeadComment, dst.LineComment, dst.FootComment
+	*dst = *src
+	dst.HeadComment, dst.LineComment, dst.FootComment = head, line, foot
+}
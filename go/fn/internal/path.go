@@ -0,0 +1,23 @@
+package internal
+
+import (
+	"strings"
+	"unicode"
+)
+
+// FileNameElement returns s for use as a part of a file name. Values taken from
+// objects, e.g. their name, can't be trusted: path separators and control
+// characters are replaced by "_", and "." and ".." are escaped, so the result
+// never leaves the directory it is joined to.
+func FileNameElement(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || unicode.IsControl(r) {
+			return '_'
+		}
+		return r
+	}, s)
+	if s == "." || s == ".." {
+		return "_" + s
+	}
+	return s
+}
@@ -89,6 +89,11 @@ func ExtractObjects(nodes ...*yaml.Node) ([]*MapVariant, error) {
 	for _, node := range nodes {
 		switch node.Kind {
 		case yaml.DocumentNode:
+			// empty and comment-only documents, e.g. after a trailing
+			// separator, hold a null scalar
+			if len(node.Content) == 1 && node.Content[0].Kind == yaml.ScalarNode && node.Content[0].ShortTag() == yaml.NodeTagNull {
+				continue
+			}
 			children, err := ExtractObjects(node.Content...)
 			if err != nil {
 				return nil, err
@@ -0,0 +1,116 @@
+// Package metrics exposes Prometheus metrics for functions that are served by
+// a long-running server, e.g. fn.Serve, rather than executed with fn.AsMain.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+const (
+	// DefaultPath is the path the metrics are served on.
+	DefaultPath = "/metrics"
+
+	namespace = "fn"
+
+	labelFunction   = "function"
+	labelAPIVersion = "origin_api_version"
+	labelKind       = "origin_kind"
+	labelStatus     = "status"
+	labelSeverity   = "severity"
+
+	statusSuccess = "success"
+	statusError   = "error"
+)
+
+// Metrics holds the collectors of the function server.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests *prometheus.CounterVec
+	duration *prometheus.HistogramVec
+	outputs  *prometheus.CounterVec
+	results  *prometheus.CounterVec
+}
+
+// New registers the function collectors with a new registry.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "requests_total",
+			Help:      "Number of function invocations, by status.",
+		}, []string{labelFunction, labelAPIVersion, labelKind, labelStatus}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_duration_seconds",
+			Help:      "Latency of function invocations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{labelFunction, labelAPIVersion, labelKind}),
+		outputs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "outputs_total",
+			Help:      "Number of outputs rendered by function invocations.",
+		}, []string{labelFunction, labelAPIVersion, labelKind}),
+		results: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "results_total",
+			Help:      "Number of results reported by function invocations, by severity.",
+		}, []string{labelFunction, labelAPIVersion, labelKind, labelSeverity}),
+	}
+	m.registry.MustRegister(m.requests, m.duration, m.outputs, m.results)
+	return m
+}
+
+// Registry returns the registry the function collectors are registered with, so
+// that other collectors can be added to the same endpoint.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler returns the handler that serves the metrics for scraping.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Observer returns a RunObserver that records the runs of the named function.
+func (m *Metrics) Observer(function string) fn.RunObserver {
+	return fn.RunObserverFunc(func(rctx *fn.ResourceContext, duration time.Duration, err error) {
+		var apiVersion, kind string
+		if rctx != nil && rctx.Input != nil && rctx.Input.Origin != nil {
+			apiVersion = rctx.Input.Origin.GetAPIVersion()
+			kind = rctx.Input.Origin.GetKind()
+		}
+		status := statusSuccess
+		if err != nil {
+			status = statusError
+		}
+		m.requests.WithLabelValues(function, apiVersion, kind, status).Inc()
+		m.duration.WithLabelValues(function, apiVersion, kind).Observe(duration.Seconds())
+		if rctx == nil {
+			return
+		}
+		m.outputs.WithLabelValues(function, apiVersion, kind).Add(float64(len(rctx.Outputs)))
+		for _, result := range rctx.Results {
+			severity := result.Severity
+			if severity == "" {
+				severity = fn.Info
+			}
+			m.results.WithLabelValues(function, apiVersion, kind, string(severity)).Inc()
+		}
+	})
+}
+
+// ServerOptions enables the metrics on fn.Serve: runs of the named function are
+// recorded and the metrics are served on DefaultPath.
+func (m *Metrics) ServerOptions(function string) []fn.ServerOption {
+	return []fn.ServerOption{
+		fn.WithServerRunOptions(fn.WithRunObserver(m.Observer(function))),
+		fn.WithServerHandler(DefaultPath, m.Handler()),
+	}
+}
@@ -0,0 +1,80 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/metrics"
+)
+
+const input = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+`
+
+func TestScrape(t *testing.T) {
+	m := metrics.New()
+	p := fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		if rctx.Input.Origin.GetName() == "broken" {
+			err := errors.New("transform failed")
+			rctx.LogResult(err)
+			return false, err
+		}
+		output, err := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+		if err != nil {
+			return false, err
+		}
+		rctx.Outputs = append(rctx.Outputs, output)
+		return true, nil
+	})
+	mux := http.NewServeMux()
+	mux.Handle("/", fn.NewHTTPHandler(p, fn.WithRunObserver(m.Observer("app-fn"))))
+	mux.Handle(metrics.DefaultPath, m.Handler())
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	for _, in := range []string{input, input, strings.Replace(input, "app1", "broken", 1)} {
+		resp, err := srv.Client().Post(srv.URL, fn.ContentTypeYAML, strings.NewReader(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("unexpected status %d", resp.StatusCode)
+		}
+	}
+
+	resp, err := srv.Client().Get(srv.URL + metrics.DefaultPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scrape := string(b)
+	const labels = `function="app-fn",origin_api_version="app.yndd.io/v1alpha1",origin_kind="App"`
+	for _, want := range []string{
+		`fn_requests_total{` + labels + `,status="success"} 2`,
+		`fn_requests_total{` + labels + `,status="error"} 1`,
+		`fn_request_duration_seconds_count{` + labels + `} 3`,
+		`fn_outputs_total{` + labels + `} 2`,
+		`fn_results_total{` + labels + `,severity="error"} 1`,
+	} {
+		if !strings.Contains(scrape, want) {
+			t.Errorf("expected the scrape to contain %q, got\n%s", want, scrape)
+		}
+	}
+}
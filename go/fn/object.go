@@ -33,6 +33,24 @@ func ParseKubeObject(in []byte) (*KubeObject, error) {
 	return asKubeObject(rlMap), nil
 }
 
+// ParseKubeObjects parses input byte slice, which can hold several yaml
+// documents, to KubeObjects.
+func ParseKubeObjects(in []byte) (KubeObjects, error) {
+	doc, err := internal.ParseDoc(in)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse input bytes: %w", err)
+	}
+	objects, err := doc.Elements()
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract objects: %w", err)
+	}
+	var kubeObjects KubeObjects
+	for _, obj := range objects {
+		kubeObjects = append(kubeObjects, asKubeObject(obj))
+	}
+	return kubeObjects, nil
+}
+
 // GetOrDie gets the value for a nested field located by fields. A pointer must
 // be passed in, and the value will be stored in ptr. If the field doesn't
 // exist, the ptr will be set to nil. It will panic if it encounters any error.
@@ -222,9 +240,8 @@ func (o *SubObject) RemoveNestedField(fields ...string) (bool, error) {
 // passed in, and the value will be stored in ptr. ptr can be a concrete type
 // (e.g. string, []corev1.Container, []string, corev1.Pod, map[string]string) or
 // a yaml.RNode. yaml.RNode should be used if you are dealing with comments that
-// is more than what LineComment, HeadComment, SetLineComment and
-// SetHeadComment can handle. It returns if the field is found and a
-// potential error.
+// is more than what the comment methods, e.g. LineComment and SetHeadComment,
+// can handle. It returns if the field is found and a potential error.
 func (o *SubObject) Get(ptr interface{}, fields ...string) (bool, error) {
 	found, err := func() (bool, error) {
 		if o == nil {
@@ -310,7 +327,9 @@ func (o *SubObject) SetOrDie(val interface{}, fields ...string) {
 
 // SetNestedField sets a nested field located by fields to the value provided as val. val
 // should not be a yaml.RNode. If you want to deal with yaml.RNode, you should
-// use Get method and modify the underlying yaml.Node.
+// use Get method and modify the underlying yaml.Node. The fields of a typed val
+// are written in sorted order; use MergeNestedField to keep the order of the
+// existing fields.
 func (o *SubObject) SetNestedField(val interface{}, fields ...string) error {
 	err := func() error {
 		if val == nil {
@@ -390,6 +409,42 @@ func (o *SubObject) SetNestedField(val interface{}, fields ...string) error {
 	return nil
 }
 
+// MergeNestedField sets the value at fields to val like SetNestedField, but
+// merges val into the value that is already there: the fields that remain keep
+// their order, quoting style and comments, and new fields are appended. With no
+// fields, val is merged into the object itself. An object that is written back
+// to its file after a MergeNestedField only differs where its values changed.
+func (o *SubObject) MergeNestedField(val interface{}, fields ...string) error {
+	const field = "value"
+	tmp := &SubObject{obj: internal.NewMap(nil)}
+	if err := tmp.SetNestedField(val, field); err != nil {
+		return err
+	}
+	src, _, err := tmp.obj.GetNestedValue(field)
+	if err != nil {
+		return err
+	}
+	if o.obj == nil {
+		o.obj = internal.NewMap(nil)
+	}
+	if len(fields) == 0 {
+		if src.Node().Kind != yaml.MappingNode {
+			return fmt.Errorf("unable to merge %v into an object: not a map", val)
+		}
+		internal.MergeNode(o.obj.Node(), src.Node())
+		return nil
+	}
+	dst, found, err := o.obj.GetNestedValue(fields...)
+	if err != nil {
+		return fmt.Errorf("unable to merge %v at fields %v with error: %w", val, fields, err)
+	}
+	if !found {
+		return o.obj.SetNestedValue(src, fields...)
+	}
+	internal.MergeNode(dst.Node(), src.Node())
+	return nil
+}
+
 // SetNestedIntOrDie sets the `fields` value to int `value`. It panics if the fields type is not int.
 func (o *SubObject) SetNestedIntOrDie(value int, fields ...string) {
 	err := o.SetNestedInt(value, fields...)
@@ -455,54 +510,6 @@ func (o *SubObject) SetNestedStringSlice(value []string, fields ...string) error
 	return o.SetNestedField(value, fields...)
 }
 
-// LineComment returns the line comment, if the target field exist and a
-// potential error.
-func (o *KubeObject) LineComment(fields ...string) (string, bool, error) {
-	rn := &yaml.RNode{}
-	found, err := o.Get(rn, fields...)
-	if !found || err != nil {
-		return "", found, err
-	}
-	return rn.YNode().LineComment, true, nil
-}
-
-// HeadComment returns the head comment, if the target field exist and a
-// potential error.
-func (o *KubeObject) HeadComment(fields ...string) (string, bool, error) {
-	rn := &yaml.RNode{}
-	found, err := o.Get(rn, fields...)
-	if !found || err != nil {
-		return "", found, err
-	}
-	return rn.YNode().HeadComment, true, nil
-}
-
-func (o *KubeObject) SetLineComment(comment string, fields ...string) error {
-	rn := &yaml.RNode{}
-	found, err := o.Get(rn, fields...)
-	if err != nil {
-		return err
-	}
-	if !found {
-		return fmt.Errorf("can't set line comment because the field doesn't exist")
-	}
-	rn.YNode().LineComment = comment
-	return nil
-}
-
-func (o *KubeObject) SetHeadComment(comment string, fields ...string) error {
-	rn := &yaml.RNode{}
-	found, err := o.Get(rn, fields...)
-	if err != nil {
-		return err
-	}
-	if !found {
-		return fmt.Errorf("can't set head comment because the field doesn't exist")
-	}
-	rn.YNode().HeadComment = comment
-	return nil
-}
-
 // AsOrDie converts a KubeObject to the desired typed object. ptr must
 // be a pointer to a typed object. It will panic if it encounters an error.
 func (o *SubObject) AsOrDie(ptr interface{}) {
@@ -0,0 +1,68 @@
+// Package oteltest provides in-memory OpenTelemetry providers to inspect the
+// spans and metrics recorded by fn.Run, e.g. in tests or when profiling a
+// transform locally.
+package oteltest
+
+import (
+	"context"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// Recorder records spans and metrics in memory.
+type Recorder struct {
+	TracerProvider *sdktrace.TracerProvider
+	MeterProvider  *sdkmetric.MeterProvider
+
+	exporter *tracetest.InMemoryExporter
+	reader   sdkmetric.Reader
+}
+
+// New returns a Recorder whose spans are exported synchronously, so they are
+// available as soon as Run returns.
+func New() *Recorder {
+	exporter := tracetest.NewInMemoryExporter()
+	reader := sdkmetric.NewManualReader()
+	return &Recorder{
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)),
+		MeterProvider:  sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader)),
+		exporter:       exporter,
+		reader:         reader,
+	}
+}
+
+// RunOptions returns the options that make Run record into r.
+func (r *Recorder) RunOptions() []fn.RunOption {
+	return []fn.RunOption{
+		fn.WithTracerProvider(r.TracerProvider),
+		fn.WithMeterProvider(r.MeterProvider),
+	}
+}
+
+// Spans returns the ended spans.
+func (r *Recorder) Spans() tracetest.SpanStubs {
+	return r.exporter.GetSpans()
+}
+
+// SpanNames returns the names of the ended spans in the order they ended.
+func (r *Recorder) SpanNames() []string {
+	var names []string
+	for _, span := range r.exporter.GetSpans() {
+		names = append(names, span.Name)
+	}
+	return names
+}
+
+// Metrics collects the metrics recorded so far.
+func (r *Recorder) Metrics(ctx context.Context) (metricdata.ResourceMetrics, error) {
+	return r.reader.Collect(ctx)
+}
+
+// Reset drops the recorded spans.
+func (r *Recorder) Reset() {
+	r.exporter.Reset()
+}
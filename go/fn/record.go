@@ -0,0 +1,169 @@
+package fn
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+)
+
+const (
+	// RecordEnv enables recording when it names a bundle directory, see
+	// WithRecorder.
+	RecordEnv = "FN_RECORD_DIR"
+
+	// RedactedValue replaces the values removed by RedactSecrets.
+	RedactedValue = "REDACTED"
+
+	recordInputFile   = "input.yaml"
+	recordOutputFile  = "output.yaml"
+	recordResultsFile = "results.yaml"
+	recordErrorFile   = "error.txt"
+)
+
+// Redactor removes sensitive data from a KubeObject before it is recorded.
+type Redactor func(obj *KubeObject)
+
+// RedactSecrets replaces the values of the data and stringData of v1 Secrets
+// with RedactedValue.
+func RedactSecrets(obj *KubeObject) {
+	if !obj.IsGVK("", "v1", "Secret") {
+		return
+	}
+	for _, field := range []string{"data", "stringData"} {
+		data, found, err := obj.NestedStringMap(field)
+		if err != nil || !found {
+			continue
+		}
+		for k := range data {
+			data[k] = RedactedValue
+		}
+		if err := obj.SetNestedStringMap(data, field); err != nil {
+			Logf("failed to redact %s of %s: %v\n", field, obj.ShortString(), err)
+		}
+	}
+}
+
+// WithRecorder records every run in its own bundle directory under dir: the
+// incoming ResourceContext, the returned ResourceContext, its Results and the
+// error of the run. The objects are passed through the redactors before they
+// are written. A bundle can be replayed with Replay. Setting RecordEnv has the
+// same effect, with RedactSecrets as redactor.
+func WithRecorder(dir string, redactors ...Redactor) RunOption {
+	return func(o *runOptions) {
+		o.recorder = &recorder{dir: dir, redactors: redactors}
+	}
+}
+
+type recorder struct {
+	dir       string
+	redactors []Redactor
+}
+
+var recordSeq uint64
+
+// record writes a bundle. Failures are logged rather than failing the run.
+func (r *recorder) record(input, out []byte, runErr error) {
+	bundle, err := r.bundleDir(input)
+	if err != nil {
+		Logf("failed to record the run: %v\n", err)
+		return
+	}
+	files := map[string][]byte{}
+	in, err := r.redact(input)
+	if err != nil {
+		// an input that can't be parsed can't be redacted either
+		Logf("failed to record the input: %v\n", err)
+	} else {
+		files[recordInputFile] = in
+	}
+	if len(out) > 0 {
+		redacted, err := r.redact(out)
+		if err != nil {
+			Logf("failed to record the output: %v\n", err)
+		} else {
+			files[recordOutputFile] = redacted
+			if rctx, err := ParseResourceContext(redacted); err == nil && len(rctx.Results) > 0 {
+				if results, err := resultsToYAML(rctx.Results); err == nil {
+					files[recordResultsFile] = results
+				}
+			}
+		}
+	}
+	if runErr != nil {
+		files[recordErrorFile] = []byte(runErr.Error() + "\n")
+	}
+	for name, b := range files {
+		if err := ioutil.WriteFile(filepath.Join(bundle, name), b, 0o600); err != nil {
+			Logf("failed to record the run: %v\n", err)
+		}
+	}
+}
+
+// bundleDir creates the bundle directory of a run, named after the time and
+// the origin of the run. Path separators in the origin are escaped, so the
+// bundle is always in the recording directory.
+func (r *recorder) bundleDir(input []byte) (string, error) {
+	name := fmt.Sprintf("%s-%d", time.Now().UTC().Format("20060102T150405.000000000"), atomic.AddUint64(&recordSeq, 1))
+	if rctx, err := ParseResourceContext(input); err == nil && rctx.Input != nil && rctx.Input.Origin != nil {
+		origin := rctx.Input.Origin
+		name = fmt.Sprintf("%s-%s-%s", name, internal.FileNameElement(strings.ToLower(origin.GetKind())), internal.FileNameElement(origin.GetName()))
+	}
+	dir := filepath.Join(r.dir, name)
+	if rel, err := filepath.Rel(r.dir, dir); err != nil || rel != name {
+		return "", fmt.Errorf("bundle %s can't be created outside %s", name, r.dir)
+	}
+	return dir, os.MkdirAll(dir, 0o700)
+}
+
+// redact passes every object of the ResourceContext in b through the
+// redactors.
+func (r *recorder) redact(b []byte) ([]byte, error) {
+	rctx, err := ParseResourceContext(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.redactors) == 0 {
+		return b, nil
+	}
+	var objs KubeObjects
+	if rctx.Input != nil {
+		for _, obj := range []*KubeObject{rctx.Input.Origin, rctx.Input.Target, rctx.Input.FunctionConfig} {
+			if obj != nil {
+				objs = append(objs, obj)
+			}
+		}
+		objs = append(objs, rctx.Input.Items...)
+	}
+	objs = append(objs, rctx.Outputs...)
+	for _, obj := range objs {
+		for _, redact := range r.redactors {
+			redact(obj)
+		}
+	}
+	return rctx.ToYAML()
+}
+
+func resultsToYAML(results Results) ([]byte, error) {
+	obj, err := NewFromTypedObject(struct {
+		Results Results `json:"results"`
+	}{results})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(obj.String()), nil
+}
+
+// recorderFromEnv returns the recorder enabled by RecordEnv, if any.
+func recorderFromEnv() *recorder {
+	dir := os.Getenv(RecordEnv)
+	if dir == "" {
+		return nil
+	}
+	return &recorder{dir: dir, redactors: []Redactor{RedactSecrets}}
+}
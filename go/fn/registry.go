@@ -0,0 +1,302 @@
+package fn
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+const (
+	// FunctionEnv selects the function to run when no name is given on the
+	// command line.
+	FunctionEnv = "FN_FUNCTION"
+
+	// FunctionAnnotation on the ResourceContext selects the function to run when
+	// neither the command line nor FunctionEnv name one.
+	FunctionAnnotation = "app.yndd.io/function"
+)
+
+// Registry holds named processors so one binary can serve several functions.
+type Registry struct {
+	mu         sync.RWMutex
+	processors map[string]ContextProcessor
+	specs      map[string]*FunctionSpec
+}
+
+// DefaultRegistry is the Registry used by Register.
+var DefaultRegistry = NewRegistry()
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		processors: map[string]ContextProcessor{},
+		specs:      map[string]*FunctionSpec{},
+	}
+}
+
+// Register registers p under name in the DefaultRegistry.
+func Register(name string, p ResourceContextProcessor) {
+	DefaultRegistry.Register(name, p)
+}
+
+// Register registers p under name. It panics if name is empty or already
+// registered, or if p is nil.
+func (r *Registry) Register(name string, p ResourceContextProcessor) {
+	if p == nil {
+		r.RegisterContext(name, nil)
+		return
+	}
+	r.RegisterContext(name, AsContextProcessor(p))
+}
+
+// RegisterContext registers the context aware p under name. It panics if name
+// is empty or already registered, or if p is nil.
+func (r *Registry) RegisterContext(name string, p ContextProcessor) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if name == "" {
+		panic("fn: Register with an empty function name")
+	}
+	if p == nil {
+		panic(fmt.Sprintf("fn: Register of function %q with a nil processor", name))
+	}
+	if _, ok := r.processors[name]; ok {
+		panic(fmt.Sprintf("fn: Register called twice for function %q", name))
+	}
+	r.processors[name] = p
+}
+
+// RegisterSpec registers p under spec.Name. The spec is enforced when the
+// function runs and printed by the describe command.
+func (r *Registry) RegisterSpec(spec *FunctionSpec, p ResourceContextProcessor) {
+	r.Register(spec.Name, p)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.specs[spec.Name] = spec
+}
+
+// Spec returns the FunctionSpec registered for name, if any.
+func (r *Registry) Spec(name string) (*FunctionSpec, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	spec, ok := r.specs[name]
+	return spec, ok
+}
+
+// Get returns the processor registered under name.
+func (r *Registry) Get(name string) (ContextProcessor, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	p, ok := r.processors[name]
+	return p, ok
+}
+
+// Names returns the sorted names of the registered functions.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.processors))
+	for name := range r.processors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// AsMain is the entrypoint of a multi-function binary. It executes the command
+// given by os.Args, see Execute.
+func (r *Registry) AsMain(opts ...RunOption) error {
+	ctx, stop := signalContext(context.Background())
+	defer stop()
+
+	err := r.Execute(ctx, os.Args[1:], os.Stdin, os.Stdout, opts...)
+	if err != nil {
+		Logf("failed to evaluate function: %v\n", err)
+	}
+	return err
+}
+
+// Execute runs a command of a multi-function binary:
+//
+//	list                    prints the registered function names
+//	describe [name]         prints the FunctionSpec of one or all functions
+//	run [-f name] [name]    runs a function on the ResourceContext read from stdin
+//	replay [-f name] dir... replays recorded bundles and reports divergences
+//
+// Without a command, run is assumed. The function to run is selected by the
+// positional name, the -f flag, the FunctionEnv environment variable or the
+// FunctionAnnotation of the ResourceContext, in that order. If a single
+// function is registered, it is selected by default. A KRM ResourceList on stdin
+// is run through the ResourceListAdapter.
+func (r *Registry) Execute(ctx context.Context, args []string, stdin io.Reader, stdout io.Writer, opts ...RunOption) error {
+	cmd := "run"
+	if len(args) > 0 && (args[0] == "run" || args[0] == "list" || args[0] == "describe" || args[0] == "replay" || args[0] == "help") {
+		cmd, args = args[0], args[1:]
+	} else if len(args) > 0 && args[0] == DescribeFlag {
+		cmd, args = "describe", args[1:]
+	}
+	switch cmd {
+	case "list":
+		for _, name := range r.Names() {
+			if _, err := fmt.Fprintln(stdout, name); err != nil {
+				return err
+			}
+		}
+		return nil
+	case "describe":
+		return r.describe(stdout, args)
+	case "help":
+		r.usage(stdout)
+		return nil
+	}
+
+	fs := flag.NewFlagSet(cmd, flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	name := fs.String("f", "", "name of the function to run")
+	fs.StringVar(name, "function", "", "name of the function to run")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cmd == "replay" {
+		return r.replay(ctx, stdout, *name, fs.Args(), opts...)
+	}
+	switch fs.NArg() {
+	case 0:
+	case 1:
+		*name = fs.Arg(0)
+	default:
+		return fmt.Errorf("run accepts a single function name, got %v", fs.Args())
+	}
+
+	in, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return fmt.Errorf("unable to read from stdin: %v", err)
+	}
+	*name, err = r.selectName(*name, in)
+	if err != nil {
+		return err
+	}
+	p, ok := r.Get(*name)
+	if !ok {
+		return fmt.Errorf("unknown function %q, registered functions: %v", *name, r.Names())
+	}
+	if spec, ok := r.Spec(*name); ok {
+		opts = append(opts, WithFunctionSpec(spec))
+	}
+	out, err := runInput(ctx, p, in, opts...)
+	// If there is an error, we don't return the error immediately.
+	// We write out to stdout before returning any error.
+	if _, outErr := stdout.Write(out); outErr != nil {
+		return outErr
+	}
+	return err
+}
+
+// selectName returns the function named by name, FunctionEnv or the
+// FunctionAnnotation of the input.
+func (r *Registry) selectName(name string, in []byte) (string, error) {
+	if name == "" {
+		name = os.Getenv(FunctionEnv)
+	}
+	if name == "" {
+		if obj, err := ParseKubeObject(in); err == nil {
+			name = obj.GetAnnotation(FunctionAnnotation)
+		}
+	}
+	if name == "" {
+		names := r.Names()
+		if len(names) != 1 {
+			return "", fmt.Errorf("no function selected; use run <name>, -f, %s or the %s annotation, registered functions: %v",
+				FunctionEnv, FunctionAnnotation, names)
+		}
+		name = names[0]
+	}
+	return name, nil
+}
+
+// replay replays the bundles recorded with WithRecorder or RecordEnv and
+// prints a report for each of them. It returns an error if a replayed run
+// diverges from its recording.
+func (r *Registry) replay(ctx context.Context, w io.Writer, name string, bundles []string, opts ...RunOption) error {
+	if len(bundles) == 0 {
+		return fmt.Errorf("replay requires at least one bundle directory")
+	}
+	var diverged []string
+	for _, bundle := range bundles {
+		input, err := ioutil.ReadFile(filepath.Join(bundle, recordInputFile))
+		if err != nil {
+			return fmt.Errorf("unable to read the recorded input: %w", err)
+		}
+		fnName, err := r.selectName(name, input)
+		if err != nil {
+			return err
+		}
+		p, ok := r.Get(fnName)
+		if !ok {
+			return fmt.Errorf("unknown function %q, registered functions: %v", fnName, r.Names())
+		}
+		fnOpts := opts
+		if spec, ok := r.Spec(fnName); ok {
+			fnOpts = append(fnOpts, WithFunctionSpec(spec))
+		}
+		report, err := Replay(ctx, p, bundle, fnOpts...)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintln(w, report); err != nil {
+			return err
+		}
+		if report.Diverged() {
+			diverged = append(diverged, bundle)
+		}
+	}
+	if len(diverged) > 0 {
+		return fmt.Errorf("replay diverged for %v", diverged)
+	}
+	return nil
+}
+
+// describe prints the FunctionSpec of the named functions, or of all functions
+// with a FunctionSpec, as a multi-document yaml stream.
+func (r *Registry) describe(w io.Writer, names []string) error {
+	if len(names) == 0 {
+		for _, name := range r.Names() {
+			if _, ok := r.Spec(name); ok {
+				names = append(names, name)
+			}
+		}
+	}
+	for i, name := range names {
+		spec, ok := r.Spec(name)
+		if !ok {
+			return fmt.Errorf("function %q has no FunctionSpec", name)
+		}
+		if i > 0 {
+			if _, err := fmt.Fprintln(w, "---"); err != nil {
+				return err
+			}
+		}
+		if err := describe(w, spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Registry) usage(w io.Writer) {
+	fmt.Fprintf(w, `Usage:
+  %[1]s list                    list the registered functions
+  %[1]s describe [name]         print the FunctionSpec of one or all functions
+  %[1]s run [-f name] [name]    run a function on the ResourceContext read from stdin
+  %[1]s replay [-f name] dir... replay recorded bundles and report divergences
+
+The function is selected by name, -f, $%[2]s or the %[3]s
+annotation of the ResourceContext.
+`, os.Args[0], FunctionEnv, FunctionAnnotation)
+}
@@ -0,0 +1,239 @@
+package fn
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// namedProcessor adds a ConfigMap output named after the function.
+func namedProcessor(name string) ResourceContextProcessorFunc {
+	return func(rctx *ResourceContext) (bool, error) {
+		obj, err := ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n"))
+		if err != nil {
+			return false, err
+		}
+		rctx.Outputs = append(rctx.Outputs, obj)
+		return true, nil
+	}
+}
+
+func testRegistry(names ...string) *Registry {
+	r := NewRegistry()
+	for _, name := range names {
+		r.Register(name, namedProcessor(name))
+	}
+	return r
+}
+
+// annotatedInput returns testInput with the FunctionAnnotation set to name.
+func annotatedInput(name string) string {
+	return strings.Replace(testInput, "kind: ResourceContext\n",
+		"kind: ResourceContext\nmetadata:\n  annotations:\n    "+FunctionAnnotation+": "+name+"\n", 1)
+}
+
+func TestRegistrySelection(t *testing.T) {
+	tests := map[string]struct {
+		names   []string
+		args    []string
+		env     string
+		input   string
+		want    string
+		wantErr string
+	}{
+		"positional name": {
+			names: []string{"a", "b", "c", "d"},
+			args:  []string{"run", "-f", "b", "a"},
+			env:   "c",
+			input: annotatedInput("d"),
+			want:  "a",
+		},
+		"flag": {
+			names: []string{"a", "b", "c", "d"},
+			args:  []string{"run", "-f", "b"},
+			env:   "c",
+			input: annotatedInput("d"),
+			want:  "b",
+		},
+		"long flag without the run command": {
+			names: []string{"a", "b"},
+			args:  []string{"--function", "b"},
+			want:  "b",
+		},
+		"environment": {
+			names: []string{"a", "b", "c", "d"},
+			env:   "c",
+			input: annotatedInput("d"),
+			want:  "c",
+		},
+		"annotation": {
+			names: []string{"a", "b", "c", "d"},
+			input: annotatedInput("d"),
+			want:  "d",
+		},
+		"single function": {
+			names: []string{"a"},
+			want:  "a",
+		},
+		"nothing selected": {
+			names:   []string{"a", "b"},
+			wantErr: "no function selected",
+		},
+		"unknown name": {
+			names:   []string{"a", "b"},
+			args:    []string{"run", "x"},
+			wantErr: `unknown function "x", registered functions: [a b]`,
+		},
+		"unknown annotation": {
+			names:   []string{"a"},
+			input:   annotatedInput("x"),
+			wantErr: `unknown function "x"`,
+		},
+		"several names": {
+			names:   []string{"a", "b"},
+			args:    []string{"run", "a", "b"},
+			wantErr: "single function name",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			t.Setenv(FunctionEnv, tc.env)
+			input := tc.input
+			if input == "" {
+				input = testInput
+			}
+			var out bytes.Buffer
+			err := testRegistry(tc.names...).Execute(context.Background(), tc.args, strings.NewReader(input), &out)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expected an error with %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			rctx, err := ParseResourceContext(out.Bytes())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rctx.Outputs) != 1 || rctx.Outputs[0].GetName() != tc.want {
+				t.Errorf("expected function %s to run, got outputs %v", tc.want, rctx.Outputs)
+			}
+		})
+	}
+}
+
+func TestRegistryList(t *testing.T) {
+	var out bytes.Buffer
+	if err := testRegistry("b", "a").Execute(context.Background(), []string{"list"}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out.String() != "a\nb\n" {
+		t.Errorf("expected the sorted names, got %q", out.String())
+	}
+}
+
+func TestRegistryDescribe(t *testing.T) {
+	r := testRegistry("plain")
+	for _, name := range []string{"b", "a"} {
+		r.RegisterSpec(&FunctionSpec{Name: name, Description: "function " + name}, namedProcessor(name))
+	}
+	tests := map[string]struct {
+		args    []string
+		want    []string
+		wantErr string
+	}{
+		"all":         {args: []string{"describe"}, want: []string{"a", "b"}},
+		"flag":        {args: []string{DescribeFlag}, want: []string{"a", "b"}},
+		"one":         {args: []string{"describe", "b"}, want: []string{"b"}},
+		"no spec":     {args: []string{"describe", "plain"}, wantErr: `function "plain" has no FunctionSpec`},
+		"unknown one": {args: []string{"describe", "x"}, wantErr: `function "x" has no FunctionSpec`},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := r.Execute(context.Background(), tc.args, nil, &out)
+			if tc.wantErr != "" {
+				if err == nil || err.Error() != tc.wantErr {
+					t.Fatalf("expected error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			docs := strings.Split(out.String(), "---\n")
+			if len(docs) != len(tc.want) {
+				t.Fatalf("expected %d documents, got %q", len(tc.want), out.String())
+			}
+			for i, doc := range docs {
+				spec, err := ParseFunctionSpec([]byte(doc))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if spec.Name != tc.want[i] || spec.Description != "function "+tc.want[i] {
+					t.Errorf("expected the spec of %s, got %+v", tc.want[i], spec)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistryReplay(t *testing.T) {
+	dir := t.TempDir()
+	r := testRegistry("a", "b")
+	var out bytes.Buffer
+	if err := r.Execute(context.Background(), []string{"run", "a"}, strings.NewReader(annotatedInput("a")), &out, WithRecorder(dir)); err != nil {
+		t.Fatal(err)
+	}
+	bundles, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(bundles) != 1 {
+		t.Fatalf("expected one recorded bundle, got %v: %v", bundles, err)
+	}
+
+	out.Reset()
+	if err := r.Execute(context.Background(), []string{"replay", bundles[0]}, nil, &out); err != nil {
+		t.Fatalf("unexpected divergence %v: %s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "no divergence") {
+		t.Errorf("expected no divergence, got %q", out.String())
+	}
+
+	// b renders another output than the recorded a
+	out.Reset()
+	err = r.Execute(context.Background(), []string{"replay", "-f", "b", bundles[0]}, nil, &out)
+	if err == nil || !strings.Contains(err.Error(), "replay diverged") {
+		t.Fatalf("expected a divergence, got %v", err)
+	}
+	if !strings.Contains(out.String(), "name=a) is missing") || !strings.Contains(out.String(), "name=b) is new") {
+		t.Errorf("unexpected report %q", out.String())
+	}
+
+	if err := r.Execute(context.Background(), []string{"replay"}, nil, ioutil.Discard); err == nil {
+		t.Error("expected an error without bundles")
+	}
+}
+
+func TestRegistryRegisterPanics(t *testing.T) {
+	tests := map[string]func(r *Registry){
+		"nil processor":         func(r *Registry) { r.Register("x", nil) },
+		"nil context processor": func(r *Registry) { r.RegisterContext("x", nil) },
+		"empty name":            func(r *Registry) { r.Register("", namedProcessor("x")) },
+		"duplicate name":        func(r *Registry) { r.Register("a", namedProcessor("a")) },
+	}
+	for name, register := range tests {
+		t.Run(name, func(t *testing.T) {
+			r := testRegistry("a")
+			defer func() {
+				if recover() == nil {
+					t.Error("expected a panic")
+				}
+			}()
+			register(r)
+		})
+	}
+}
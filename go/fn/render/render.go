@@ -0,0 +1,286 @@
+// Package render provides a ResourceContextProcessor that renders Go
+// text/template files into outputs, for the functions that fill a template
+// from the origin spec.
+package render
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"text/template"
+
+	"github.com/Masterminds/sprig/v3"
+	"github.com/yndd/app-functions-sdk/go/fn"
+	targetv1 "github.com/yndd/target/apis/target/v1"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+const (
+	// TemplateTag is the Result tag holding the name of the template an error
+	// occurred in.
+	TemplateTag = "template"
+	// LineTag is the Result tag holding the template line an error occurred at.
+	LineTag = "line"
+)
+
+// Data is the data the templates are executed with.
+type Data struct {
+	// Origin is Input.Origin.
+	Origin map[string]interface{}
+	// Target is Input.Target, typed via ResourceContext.GetTarget. It is nil if
+	// there is no target or the target is not a Target.
+	Target *targetv1.Target
+	// TargetObject is Input.Target.
+	TargetObject map[string]interface{}
+	// Items are Input.Items.
+	Items []map[string]interface{}
+	// FunctionConfig is Input.FunctionConfig.
+	FunctionConfig map[string]interface{}
+}
+
+// Processor renders its templates into the outputs of a ResourceContext. Every
+// template can render several yaml documents; each becomes an output. The
+// templates are rendered in the order of their names. Templates declared with
+// define are helpers for the other templates and are not rendered themselves.
+type Processor struct {
+	root *template.Template
+	// names are the names of the parsed templates, in contrast to the
+	// templates they define.
+	names []string
+}
+
+// New returns a Processor without templates.
+func New() *Processor {
+	return &Processor{root: template.New("").Funcs(FuncMap())}
+}
+
+// Parse returns a Processor for a single template text.
+func Parse(name, text string) (*Processor, error) {
+	return New().Parse(name, text)
+}
+
+// ParseFiles returns a Processor for the given template files, see
+// Processor.ParseFiles.
+func ParseFiles(paths ...string) (*Processor, error) {
+	return New().ParseFiles(paths...)
+}
+
+// ParseFS returns a Processor for the template files in fsys, see
+// Processor.ParseFS.
+func ParseFS(fsys fs.FS, patterns ...string) (*Processor, error) {
+	return New().ParseFS(fsys, patterns...)
+}
+
+// Parse adds a template named name with text. Parse errors are returned as a
+// *fn.Result tagged with the template and line they occurred at, as for
+// Process; the same holds for ParseFiles and ParseFS.
+func (p *Processor) Parse(name, text string) (*Processor, error) {
+	if err := p.parse(name, text); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *Processor) parse(name, text string) error {
+	if _, err := p.root.New(name).Parse(text); err != nil {
+		return templateResult(name, err)
+	}
+	for _, n := range p.names {
+		if n == name {
+			return nil
+		}
+	}
+	p.names = append(p.names, name)
+	return nil
+}
+
+// ParseFiles adds the given template files and the *.tmpl, *.tpl and *.yaml
+// files in the given directories. Templates are named after their file name.
+func (p *Processor) ParseFiles(paths ...string) (*Processor, error) {
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() || file != path && !isTemplate(file) {
+				return nil
+			}
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			return p.parse(filepath.Base(file), string(b))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return p, nil
+}
+
+// ParseFS adds the template files in fsys that match one of the patterns, e.g.
+// templates embedded with go:embed.
+func (p *Processor) ParseFS(fsys fs.FS, patterns ...string) (*Processor, error) {
+	for _, pattern := range patterns {
+		files, err := fs.Glob(fsys, pattern)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			b, err := fs.ReadFile(fsys, file)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.parse(filepath.Base(file), string(b)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p, nil
+}
+
+// Funcs adds funcs to the functions available to the templates. It must be
+// called before the templates that use them are parsed.
+func (p *Processor) Funcs(funcs template.FuncMap) *Processor {
+	p.root.Funcs(funcs)
+	return p
+}
+
+// FuncMap returns the functions available to the templates: the sprig
+// functions plus toYaml and fromYaml.
+func FuncMap() template.FuncMap {
+	funcs := sprig.TxtFuncMap()
+	funcs["toYaml"] = toYAML
+	funcs["fromYaml"] = fromYAML
+	return funcs
+}
+
+// Process renders the templates with the Data of rctx and adds the rendered
+// documents to the outputs. Template errors are reported as Results tagged with
+// the template and line they occurred at.
+func (p *Processor) Process(rctx *fn.ResourceContext) (bool, error) {
+	data, err := newData(rctx)
+	if err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+	var results fn.Results
+	for _, t := range p.templates() {
+		var b bytes.Buffer
+		if err := t.Execute(&b, data); err != nil {
+			results = append(results, templateResult(t.Name(), err))
+			continue
+		}
+		outputs, err := fn.ParseKubeObjects(b.Bytes())
+		if err != nil {
+			result := fn.GeneralResult(fmt.Sprintf("template %s rendered invalid yaml: %v", t.Name(), err), fn.Error)
+			result.Tags = map[string]string{TemplateTag: t.Name()}
+			results = append(results, result)
+			continue
+		}
+		rctx.Outputs = append(rctx.Outputs, outputs...)
+	}
+	if len(results) > 0 {
+		rctx.LogResult(results)
+		return false, results
+	}
+	return true, nil
+}
+
+// templates returns the parsed templates sorted by name, leaving out the
+// templates they define and the templates without content.
+func (p *Processor) templates() []*template.Template {
+	var templates []*template.Template
+	for _, name := range p.names {
+		t := p.root.Lookup(name)
+		if t != nil && t.Tree != nil && t.Tree.Root != nil && len(t.Tree.Root.Nodes) > 0 {
+			templates = append(templates, t)
+		}
+	}
+	sort.Slice(templates, func(i, j int) bool { return templates[i].Name() < templates[j].Name() })
+	return templates
+}
+
+var templateErrorLine = regexp.MustCompile(`template: ([^:]+):(\d+)`)
+
+// templateResult converts a template error to a Result pointing at the
+// template line.
+func templateResult(name string, err error) *fn.Result {
+	result := fn.ErrorResult(err)
+	result.Tags = map[string]string{TemplateTag: name}
+	if m := templateErrorLine.FindStringSubmatch(err.Error()); m != nil {
+		result.Tags[TemplateTag] = m[1]
+		result.Tags[LineTag] = m[2]
+	}
+	return result
+}
+
+func newData(rctx *fn.ResourceContext) (*Data, error) {
+	data := &Data{}
+	if rctx.Input == nil {
+		return data, nil
+	}
+	var err error
+	if data.Origin, err = toMap(rctx.Input.Origin); err != nil {
+		return nil, err
+	}
+	if data.TargetObject, err = toMap(rctx.Input.Target); err != nil {
+		return nil, err
+	}
+	if rctx.Input.Target != nil {
+		if t, err := rctx.GetTarget(); err == nil {
+			data.Target = t
+		}
+	}
+	if data.FunctionConfig, err = toMap(rctx.Input.FunctionConfig); err != nil {
+		return nil, err
+	}
+	for _, item := range rctx.Input.Items {
+		m, err := toMap(item)
+		if err != nil {
+			return nil, err
+		}
+		data.Items = append(data.Items, m)
+	}
+	return data, nil
+}
+
+func toMap(obj *fn.KubeObject) (map[string]interface{}, error) {
+	if obj == nil {
+		return nil, nil
+	}
+	m := map[string]interface{}{}
+	if err := obj.As(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func toYAML(v interface{}) (string, error) {
+	b, err := sigsyaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes.TrimSuffix(b, []byte("\n"))), nil
+}
+
+func fromYAML(s string) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := sigsyaml.Unmarshal([]byte(s), &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func isTemplate(file string) bool {
+	switch filepath.Ext(file) {
+	case ".tmpl", ".tpl", ".yaml":
+		return true
+	}
+	return false
+}
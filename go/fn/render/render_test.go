@@ -0,0 +1,138 @@
+package render
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// testContext returns a ResourceContext with an origin named app1.
+func testContext(t *testing.T) *fn.ResourceContext {
+	t.Helper()
+	origin, err := fn.ParseKubeObject([]byte("apiVersion: app.yndd.io/v1alpha1\nkind: App\nmetadata:\n  name: app1\nspec:\n  replicas: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fn.ResourceContext{Input: &fn.ResourceContextInputs{Origin: origin}}
+}
+
+func TestProcess(t *testing.T) {
+	p, err := ParseFS(fstest.MapFS{
+		"templates/b.yaml": {Data: []byte(`{{ define "name" }}{{ .Origin.metadata.name }}-b{{ end -}}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ template "name" . }}
+`)},
+		"templates/a.yaml": {Data: []byte(`{{- range $i := until (int .Origin.spec.replicas) }}
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ $.Origin.metadata.name }}-a{{ $i }}
+{{- end }}
+`)},
+		"templates/helpers.tpl": {Data: []byte(`{{ define "unused" }}{{ end }}`)},
+	}, "templates/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rctx := testContext(t)
+	success, err := p.Process(rctx)
+	if err != nil || !success {
+		t.Fatalf("expected success, got %v, %v", success, err)
+	}
+	var names []string
+	for _, output := range rctx.Outputs {
+		names = append(names, output.GetName())
+	}
+	if want := []string{"app1-a0", "app1-a1", "app1-b"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("expected the outputs %v in the order of the templates, got %v", want, names)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := Parse("a.yaml", "kind: ConfigMap\nname: {{ end }}\n")
+	var result *fn.Result
+	if !errors.As(err, &result) {
+		t.Fatalf("expected a Result, got %v", err)
+	}
+	if want := map[string]string{TemplateTag: "a.yaml", LineTag: "2"}; !reflect.DeepEqual(result.Tags, want) {
+		t.Errorf("expected the tags %v, got %v", want, result.Tags)
+	}
+	if result.Severity != fn.Error {
+		t.Errorf("expected an error Result, got %s", result.Severity)
+	}
+}
+
+func TestProcessErrors(t *testing.T) {
+	tests := map[string]struct {
+		templates map[string]string
+		want      []map[string]string
+		message   string
+	}{
+		"execution": {
+			templates: map[string]string{"a.yaml": "kind: ConfigMap\n\nname: {{ fail \"no name\" }}\n"},
+			want:      []map[string]string{{TemplateTag: "a.yaml", LineTag: "3"}},
+			message:   "no name",
+		},
+		"missing field": {
+			templates: map[string]string{"a.yaml": "kind: ConfigMap\nname: {{ .Origin.spec.replicas.value }}\n"},
+			want:      []map[string]string{{TemplateTag: "a.yaml", LineTag: "2"}},
+			message:   "can't evaluate field value",
+		},
+		// the error points at the file of the defined template
+		"defined template": {
+			templates: map[string]string{
+				"a.yaml":   "kind: ConfigMap\nname: {{ template \"name\" . }}\n",
+				"_helpers": "{{ define \"name\" }}\n{{ fail \"in helper\" }}{{ end }}",
+			},
+			want:    []map[string]string{{TemplateTag: "_helpers", LineTag: "2"}},
+			message: "in helper",
+		},
+		"invalid yaml": {
+			templates: map[string]string{"a.yaml": "kind: ConfigMap\n  name: [\n"},
+			want:      []map[string]string{{TemplateTag: "a.yaml"}},
+			message:   "template a.yaml rendered invalid yaml",
+		},
+		"every template": {
+			templates: map[string]string{
+				"a.yaml": "{{ fail \"a\" }}",
+				"b.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: b\n",
+				"c.yaml": "\n{{ fail \"c\" }}",
+			},
+			want:    []map[string]string{{TemplateTag: "a.yaml", LineTag: "1"}, {TemplateTag: "c.yaml", LineTag: "2"}},
+			message: "a",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := New()
+			for name, text := range tc.templates {
+				if _, err := p.Parse(name, text); err != nil {
+					t.Fatal(err)
+				}
+			}
+			rctx := testContext(t)
+			success, err := p.Process(rctx)
+			var results fn.Results
+			if success || !errors.As(err, &results) {
+				t.Fatalf("expected the Results as error, got %v, %v", success, err)
+			}
+			var tags []map[string]string
+			for _, result := range rctx.Results {
+				tags = append(tags, result.Tags)
+			}
+			if !reflect.DeepEqual(tags, tc.want) {
+				t.Errorf("expected the tags %v, got %v", tc.want, tags)
+			}
+			if !strings.Contains(rctx.Results[0].Message, tc.message) {
+				t.Errorf("expected the message to contain %q, got %q", tc.message, rctx.Results[0].Message)
+			}
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package fn
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ReplayReport is the outcome of replaying a recorded bundle.
+type ReplayReport struct {
+	// Bundle is the replayed bundle directory.
+	Bundle string
+	// Output is the ResourceContext returned by the replayed run.
+	Output []byte
+	// Err is the error of the replayed run.
+	Err error
+	// Divergences describe how the replayed run differs from the recorded run.
+	Divergences []string
+}
+
+// Diverged tells whether the replayed run differs from the recorded run.
+func (r *ReplayReport) Diverged() bool {
+	return len(r.Divergences) > 0
+}
+
+func (r *ReplayReport) String() string {
+	if !r.Diverged() {
+		return fmt.Sprintf("%s: no divergence", r.Bundle)
+	}
+	return fmt.Sprintf("%s: %d divergence(s)\n  %s", r.Bundle, len(r.Divergences), strings.Join(r.Divergences, "\n  "))
+}
+
+// Replayer re-runs processors against recorded bundles.
+type Replayer struct {
+	// Redactors are applied to both the recorded and the replayed output
+	// before they are compared, so the values the bundle may have been
+	// redacted of don't diverge.
+	Redactors []Redactor
+}
+
+// Replay re-runs p against the bundle recorded by WithRecorder or RecordEnv,
+// with the redactors of RecordEnv, see Replayer.Replay.
+func Replay(ctx context.Context, p ContextProcessor, bundle string, opts ...RunOption) (*ReplayReport, error) {
+	return (&Replayer{Redactors: []Redactor{RedactSecrets}}).Replay(ctx, p, bundle, opts...)
+}
+
+// Replay re-runs p against the input of bundle and reports how the outputs,
+// Results and error differ from the recorded ones. The replayed run is not
+// recorded itself. It returns an error if the bundle can't be read.
+func (r *Replayer) Replay(ctx context.Context, p ContextProcessor, bundle string, opts ...RunOption) (*ReplayReport, error) {
+	input, err := ioutil.ReadFile(filepath.Join(bundle, recordInputFile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the recorded input: %w", err)
+	}
+	recordedOut, err := readOptional(filepath.Join(bundle, recordOutputFile))
+	if err != nil {
+		return nil, err
+	}
+	recordedErr, err := readOptional(filepath.Join(bundle, recordErrorFile))
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ReplayReport{Bundle: bundle}
+	report.Output, report.Err = RunContext(ctx, p, input, append(opts, withoutRecorder())...)
+
+	// the redactors are applied to both sides, whatever the bundle was
+	// recorded with
+	rec := &recorder{redactors: r.Redactors}
+	if len(recordedOut) > 0 {
+		if redacted, err := rec.redact(recordedOut); err == nil {
+			recordedOut = redacted
+		}
+	}
+	var replayedOut []byte
+	if len(report.Output) > 0 {
+		if replayedOut, err = rec.redact(report.Output); err != nil {
+			report.Divergences = append(report.Divergences, fmt.Sprintf("the replayed output can't be parsed: %v", err))
+			return report, nil
+		}
+	}
+	report.Divergences = append(report.Divergences, divergences(recordedOut, replayedOut)...)
+
+	var replayedErr string
+	if report.Err != nil {
+		replayedErr = report.Err.Error()
+	}
+	if strings.TrimSpace(string(recordedErr)) != strings.TrimSpace(replayedErr) {
+		report.Divergences = append(report.Divergences, fmt.Sprintf("error: recorded %q, replayed %q",
+			strings.TrimSpace(string(recordedErr)), replayedErr))
+	}
+	return report, nil
+}
+
+// divergences compares the outputs and Results of two ResourceContexts.
+func divergences(recorded, replayed []byte) []string {
+	switch {
+	case len(recorded) == 0 && len(replayed) == 0:
+		return nil
+	case len(recorded) == 0:
+		return []string{"the recorded run returned no output"}
+	case len(replayed) == 0:
+		return []string{"the replayed run returned no output"}
+	}
+	rec, err := ParseResourceContext(recorded)
+	if err != nil {
+		return []string{fmt.Sprintf("the recorded output can't be parsed: %v", err)}
+	}
+	rep, err := ParseResourceContext(replayed)
+	if err != nil {
+		return []string{fmt.Sprintf("the replayed output can't be parsed: %v", err)}
+	}
+
+	var diffs []string
+	recOutputs, repOutputs := outputsByID(rec.Outputs), outputsByID(rep.Outputs)
+	for _, id := range sortedKeys(recOutputs, repOutputs) {
+		recObj, inRec := recOutputs[id]
+		repObj, inRep := repOutputs[id]
+		switch {
+		case !inRep:
+			diffs = append(diffs, fmt.Sprintf("output %s is missing", id))
+		case !inRec:
+			diffs = append(diffs, fmt.Sprintf("output %s is new", id))
+		case recObj.String() != repObj.String():
+			diffs = append(diffs, fmt.Sprintf("output %s changed", id))
+		}
+	}
+	if rec.Results.Error() != rep.Results.Error() {
+		diffs = append(diffs, fmt.Sprintf("results: recorded %d, replayed %d:\n    recorded: %s\n    replayed: %s",
+			len(rec.Results), len(rep.Results), resultList(rec.Results), resultList(rep.Results)))
+	}
+	return diffs
+}
+
+func outputsByID(objs KubeObjects) map[string]*KubeObject {
+	m := map[string]*KubeObject{}
+	for _, obj := range objs {
+		m[obj.ShortString()] = obj
+	}
+	return m
+}
+
+func sortedKeys(maps ...map[string]*KubeObject) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func resultList(results Results) string {
+	var msgs []string
+	for _, result := range results {
+		msgs = append(msgs, result.String())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func readOptional(file string) ([]byte, error) {
+	b, err := ioutil.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return b, err
+}
+
+// withoutRecorder disables recording, e.g. for a replayed run.
+func withoutRecorder() RunOption {
+	return func(o *runOptions) {
+		o.recorder = nil
+	}
+}
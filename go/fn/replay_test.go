@@ -0,0 +1,91 @@
+package fn
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// recordRun runs p on testInput with a recorder and returns the bundle.
+func recordRun(t *testing.T, p ContextProcessor, redactors ...Redactor) string {
+	t.Helper()
+	dir := t.TempDir()
+	if _, err := RunContext(context.Background(), p, []byte(testInput), WithRecorder(dir, redactors...)); err != nil {
+		t.Fatal(err)
+	}
+	bundles, err := filepath.Glob(filepath.Join(dir, "*"))
+	if err != nil || len(bundles) != 1 {
+		t.Fatalf("expected a single bundle, got %v, %v", bundles, err)
+	}
+	return bundles[0]
+}
+
+// secretProcessor outputs a Secret holding password.
+func secretProcessor(password string) ContextProcessor {
+	return ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		secret, err := ParseKubeObject([]byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: s\n"))
+		if err != nil {
+			return false, err
+		}
+		if err := secret.SetNestedStringMap(map[string]string{"password": password}, "stringData"); err != nil {
+			return false, err
+		}
+		rctx.Outputs = append(rctx.Outputs, secret)
+		return true, nil
+	})
+}
+
+func TestRecordReplay(t *testing.T) {
+	bundle := recordRun(t, secretProcessor("s3cr3t"), RedactSecrets)
+	out, err := os.ReadFile(filepath.Join(bundle, recordOutputFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), "s3cr3t") || !strings.Contains(string(out), RedactedValue) {
+		t.Fatalf("expected the recorded Secret to be redacted\n%s", out)
+	}
+
+	report, err := Replay(context.Background(), secretProcessor("s3cr3t"), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Diverged() {
+		t.Errorf("expected no divergence, got %s", report)
+	}
+	if !strings.Contains(string(report.Output), "s3cr3t") {
+		t.Errorf("expected the replayed output not to be redacted\n%s", report.Output)
+	}
+
+	// a redacted value that changed doesn't diverge, as it is not recorded
+	report, err = Replay(context.Background(), secretProcessor("changed"), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Diverged() {
+		t.Errorf("expected no divergence for a redacted value, got %s", report)
+	}
+}
+
+func TestReplayRedactsBothSides(t *testing.T) {
+	// the bundle is recorded without redactors and replayed with RedactSecrets
+	bundle := recordRun(t, secretProcessor("s3cr3t"))
+	report, err := Replay(context.Background(), secretProcessor("s3cr3t"), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Diverged() {
+		t.Errorf("expected no divergence, got %s", report)
+	}
+
+	// without redactors, a changed Secret diverges
+	report, err = (&Replayer{}).Replay(context.Background(), secretProcessor("changed"), bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "output Resource(apiVersion=v1, kind=Secret, namespace=, name=s) changed"
+	if len(report.Divergences) != 1 || report.Divergences[0] != want {
+		t.Errorf("expected %q, got %s", want, report)
+	}
+}
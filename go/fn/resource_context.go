@@ -16,26 +16,47 @@ const (
 	ResourceContextAPIVersion = "app.yndd.io/v1"
 )
 
+// ResourceContext is the input and the response of a function. In yaml, the
+// inputs are nested under input and the outputs and results are at the top
+// level, e.g.
+//
+//	apiVersion: app.yndd.io/v1
+//	kind: ResourceContext
+//	input:
+//	  origin: {...}
+//	  target: {...}
+//	  functionConfig: {...}
+//	  items: [...]
+//	outputs: [...]
+//	results: [...]
+//
+// ParseResourceContext also reads the origin, the target and the items from the
+// top level, where ToYAML wrote them before they were nested under input.
 type ResourceContext struct {
 	Input   *ResourceContextInputs `yaml:"input" json:"input"`                         // the input CR(s)
 	Outputs KubeObjects            `yaml:"outputs,omitempty" json:"outputs,omitempty"` // the rendered output CR
 	Results Results                `yaml:"results,omitempty" json:"results,omitempty"` // result context
+
+	// doc is the parsed document, written back by ToYAMLWithFormat.
+	doc *internal.MapVariant
 }
 
 type ResourceContextInputs struct {
 	Origin *KubeObject `yaml:"origin" json:"origin"`                     // the origin CR in the intent/app
 	Target *KubeObject `yaml:"target,omitempty" json:"target,omitempty"` // could be node or target
 	Items  KubeObjects `yaml:"items,omitempty" json:"items,omitempty"`   // additional input items like OC
+	// the configuration of the function, e.g. a ConfigMap or a typed config CR
+	FunctionConfig *KubeObject `yaml:"functionConfig,omitempty" json:"functionConfig,omitempty"`
 }
 
 // ParseResourceContext parses a ResourceContext from the input byte array. This function can be used to parse either KRM fn input
 // or KRM fn output
 func ParseResourceContext(in []byte) (*ResourceContext, error) {
-	rctx := &ResourceContext{}
 	rctxObj, err := ParseKubeObject(in)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse input bytes: %w", err)
 	}
+	rctx := &ResourceContext{doc: rctxObj.obj}
 	if rctxObj.GetKind() != ResourceContextKind {
 		return nil, fmt.Errorf("input was of unexpected kind %q; expected %s", rctxObj.GetKind(), ResourceContextKind)
 	}
@@ -48,7 +69,13 @@ func ParseResourceContext(in []byte) (*ResourceContext, error) {
 		return nil, fmt.Errorf("failed when tried to get input: %w", err)
 	}
 	if !found {
-		return nil, fmt.Errorf("input was of expected but not found in %s", ResourceContextKind)
+		// the top level layout written by earlier versions of ToYAML
+		if _, legacy, _ := rctxObj.obj.GetNestedMap("origin"); !legacy {
+			return nil, fmt.Errorf("input was of expected but not found in %s", ResourceContextKind)
+		}
+		input = rctxObj.obj
+		// it is written back in the nested layout
+		rctx.doc = nil
 	}
 	rctx.Input = &ResourceContextInputs{}
 	// Parse origin, Origin cannot be empty, e.g. an input ResourceContext always need to origin CR.
@@ -68,6 +95,14 @@ func ParseResourceContext(in []byte) (*ResourceContext, error) {
 	if found {
 		rctx.Input.Target = asKubeObject(target)
 	}
+	// Parse functionConfig, FunctionConfig can be empty
+	functionConfig, found, err := input.GetNestedMap("functionConfig")
+	if err != nil {
+		return nil, fmt.Errorf("failed when tried to get functionConfig: %w", err)
+	}
+	if found {
+		rctx.Input.FunctionConfig = asKubeObject(functionConfig)
+	}
 	// Parse items, Items can be empty, serve as additional input context
 	items, found, err := input.GetNestedSlice("items")
 	if err != nil {
@@ -93,7 +128,7 @@ func ParseResourceContext(in []byte) (*ResourceContext, error) {
 			return nil, fmt.Errorf("failed to extract objects from ouputs: %w", err)
 		}
 		for i := range objectOutputs {
-			rctx.Outputs = append(rctx.Input.Items, asKubeObject(objectOutputs[i]))
+			rctx.Outputs = append(rctx.Outputs, asKubeObject(objectOutputs[i]))
 		}
 	}
 	// Parse Results. Results can be empty.
@@ -113,67 +148,102 @@ func ParseResourceContext(in []byte) (*ResourceContext, error) {
 	return rctx, nil
 }
 
-// toYNode converts the ResourceContext to the yaml.Node representation.
-func (rctx *ResourceContext) toYNode() (*yaml.Node, error) {
-	reMap := internal.NewMap(nil)
+// toYNode converts the ResourceContext to the yaml.Node representation. The
+// fields are set in reMap, which is a new map when nil.
+func (rctx *ResourceContext) toYNode(reMap *internal.MapVariant) (*yaml.Node, error) {
+	if reMap == nil {
+		reMap = internal.NewMap(nil)
+	}
 	reObj := &KubeObject{SubObject{reMap}}
 	reObj.SetAPIVersion(ResourceContextAPIVersion)
 	reObj.SetKind(ResourceContextKind)
 
+	input := &ResourceContextInputs{}
 	if rctx.Input != nil {
-		if rctx.Input.Origin != nil {
-			if err := reMap.SetNestedMap(rctx.Input.Origin.node(), "origin"); err != nil {
-				return nil, err
-			}
-		}
-		if rctx.Input.Target != nil {
-			if err := reMap.SetNestedMap(rctx.Input.Origin.node(), "target"); err != nil {
-				return nil, err
-			}
-		}
-		if rctx.Input.Items != nil && len(rctx.Input.Items) > 0 {
-			itemsSlice := internal.NewSliceVariant()
-			for i := range rctx.Input.Items {
-				itemsSlice.Add(rctx.Input.Items[i].node())
-			}
-			if err := reMap.SetNestedSlice(itemsSlice, "items"); err != nil {
-				return nil, err
-			}
-		}
+		input = rctx.Input
 	}
-
-	if rctx.Outputs != nil && len(rctx.Outputs) > 0 {
-		outputsSlice := internal.NewSliceVariant()
-		for i := range rctx.Outputs {
-			outputsSlice.Add(rctx.Outputs[i].node())
-		}
-		if err := reMap.SetNestedSlice(outputsSlice, "outputs"); err != nil {
-			return nil, err
-		}
+	if err := setNestedObject(reMap, input.Origin, "input", "origin"); err != nil {
+		return nil, err
+	}
+	if err := setNestedObject(reMap, input.Target, "input", "target"); err != nil {
+		return nil, err
+	}
+	if err := setNestedObject(reMap, input.FunctionConfig, "input", "functionConfig"); err != nil {
+		return nil, err
+	}
+	if err := setNestedObjects(reMap, input.Items, "input", "items"); err != nil {
+		return nil, err
+	}
+	if err := setNestedObjects(reMap, rctx.Outputs, "outputs"); err != nil {
+		return nil, err
 	}
 
-	if rctx.Results != nil && len(rctx.Results) > 0 {
-		resultsSlice := internal.NewSliceVariant()
-		for _, result := range rctx.Results {
-			mv, err := internal.TypedObjectToMapVariant(result)
-			if err != nil {
-				return nil, err
-			}
-			resultsSlice.Add(mv)
-		}
-		if err := reMap.SetNestedSlice(resultsSlice, "results"); err != nil {
+	var results []*internal.MapVariant
+	for _, result := range rctx.Results {
+		mv, err := internal.TypedObjectToMapVariant(result)
+		if err != nil {
 			return nil, err
 		}
+		results = append(results, mv)
+	}
+	if err := setNestedSequence(reMap, results, "results"); err != nil {
+		return nil, err
 	}
 
 	return reMap.Node(), nil
 }
 
+// setNestedObject sets the field located by fields to obj, or removes it when
+// obj is nil.
+func setNestedObject(m *internal.MapVariant, obj *KubeObject, fields ...string) error {
+	if obj == nil {
+		_, err := m.RemoveNestedField(fields...)
+		return err
+	}
+	return m.SetNestedMap(obj.node(), fields...)
+}
+
+// setNestedObjects sets the field located by fields to the list of objs, or
+// removes it when objs is empty.
+func setNestedObjects(m *internal.MapVariant, objs KubeObjects, fields ...string) error {
+	maps := make([]*internal.MapVariant, 0, len(objs))
+	for _, obj := range objs {
+		maps = append(maps, obj.node())
+	}
+	return setNestedSequence(m, maps, fields...)
+}
+
+// setNestedSequence sets the field located by fields to the list of elems, or
+// removes it when elems is empty. An existing list is updated in place, so it
+// keeps its style and comments.
+func setNestedSequence(m *internal.MapVariant, elems []*internal.MapVariant, fields ...string) error {
+	if len(elems) == 0 {
+		_, err := m.RemoveNestedField(fields...)
+		return err
+	}
+	_, value, found, err := m.GetNestedEntry(fields...)
+	if err != nil {
+		return err
+	}
+	if found && value.Kind == yaml.SequenceNode {
+		value.Content = nil
+		for _, elem := range elems {
+			value.Content = append(value.Content, elem.Node())
+		}
+		return nil
+	}
+	slice := internal.NewSliceVariant()
+	for _, elem := range elems {
+		slice.Add(elem)
+	}
+	return m.SetNestedSlice(slice, fields...)
+}
+
 // ToYAML converts the ResourceList to yaml.
 func (rctx *ResourceContext) ToYAML() ([]byte, error) {
 	// Sort the resources input.Items and outputs first.
 	rctx.Sort()
-	ynode, err := rctx.toYNode()
+	ynode, err := rctx.toYNode(nil)
 	if err != nil {
 		return nil, err
 	}
@@ -181,10 +251,26 @@ func (rctx *ResourceContext) ToYAML() ([]byte, error) {
 	return doc.ToYAML()
 }
 
+// ToYAMLWithFormat converts the ResourceContext to yaml in the Format f. Unlike
+// ToYAML, it doesn't sort the items and outputs, and a parsed ResourceContext
+// is written back into the document it was parsed from, so its fields keep
+// their order, style and comments. A ResourceContext that wasn't changed is
+// written as it was read in its Format, except for blank lines, which the yaml
+// parser drops.
+func (rctx *ResourceContext) ToYAMLWithFormat(f Format) ([]byte, error) {
+	ynode, err := rctx.toYNode(rctx.doc)
+	if err != nil {
+		return nil, err
+	}
+	return f.Marshal(asKubeObject(internal.NewMap(ynode)))
+}
+
 // Sort sorts the ResourceContext.input by apiVersion, kind, namespace and name.
 // Sort sorts the ResourceContext.output by apiVersion, kind, namespace and name.
 func (rctx *ResourceContext) Sort() {
-	sort.Sort(rctx.Input.Items)
+	if rctx.Input != nil {
+		sort.Sort(rctx.Input.Items)
+	}
 	sort.Sort(rctx.Outputs)
 }
 
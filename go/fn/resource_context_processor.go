@@ -1,5 +1,7 @@
 package fn
 
+import "context"
+
 // ResourceContextProcessor interface
 type ResourceContextProcessor interface {
 	Process(rc *ResourceContext) (bool, error)
@@ -12,3 +14,39 @@ type ResourceContextProcessorFunc func(rc *ResourceContext) (bool, error)
 func (p ResourceContextProcessorFunc) Process(rc *ResourceContext) (bool, error) {
 	return p(rc)
 }
+
+// ContextProcessor is a context aware ResourceContextProcessor. The context is
+// cancelled when the invocation deadline is exceeded or the caller goes away.
+type ContextProcessor interface {
+	ProcessContext(ctx context.Context, rc *ResourceContext) (bool, error)
+}
+
+// ContextProcessorFunc converts a compatible function to a ContextProcessor.
+// ContextProcessorFunc implements a ContextProcessor interface
+type ContextProcessorFunc func(ctx context.Context, rc *ResourceContext) (bool, error)
+
+func (p ContextProcessorFunc) ProcessContext(ctx context.Context, rc *ResourceContext) (bool, error) {
+	return p(ctx, rc)
+}
+
+// AsContextProcessor adapts a ResourceContextProcessor to a ContextProcessor.
+// The context is ignored by the wrapped processor.
+func AsContextProcessor(p ResourceContextProcessor) ContextProcessor {
+	if cp, ok := p.(ContextProcessor); ok {
+		return cp
+	}
+	return ContextProcessorFunc(func(_ context.Context, rc *ResourceContext) (bool, error) {
+		return p.Process(rc)
+	})
+}
+
+// AsResourceContextProcessor adapts a ContextProcessor to a ResourceContextProcessor.
+// The wrapped processor is called with a background context.
+func AsResourceContextProcessor(p ContextProcessor) ResourceContextProcessor {
+	if rp, ok := p.(ResourceContextProcessor); ok {
+		return rp
+	}
+	return ResourceContextProcessorFunc(func(rc *ResourceContext) (bool, error) {
+		return p.ProcessContext(context.Background(), rc)
+	})
+}
@@ -0,0 +1,86 @@
+package fn
+
+import (
+	"testing"
+)
+
+func TestParseResourceContextLayout(t *testing.T) {
+	tests := map[string]string{
+		"nested": `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+  target:
+    apiVersion: target.yndd.io/v1
+    kind: Target
+    metadata:
+      name: dev1
+  items:
+  - apiVersion: v1
+    kind: Node
+    metadata:
+      name: node1
+outputs:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+`,
+		"top level": `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+origin:
+  apiVersion: app.yndd.io/v1alpha1
+  kind: App
+  metadata:
+    name: app1
+target:
+  apiVersion: target.yndd.io/v1
+  kind: Target
+  metadata:
+    name: dev1
+items:
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: node1
+outputs:
+- apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: cm
+`,
+	}
+	for name, in := range tests {
+		t.Run(name, func(t *testing.T) {
+			rctx, err := ParseResourceContext([]byte(in))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if rctx.Input.Origin.GetName() != "app1" || rctx.Input.Target == nil || rctx.Input.Target.GetName() != "dev1" {
+				t.Errorf("unexpected origin %v and target %v", rctx.Input.Origin, rctx.Input.Target)
+			}
+			if len(rctx.Input.Items) != 1 || len(rctx.Outputs) != 1 || rctx.Outputs[0].GetName() != "cm" {
+				t.Errorf("expected 1 item and the output, got items %v and outputs %v", rctx.Input.Items, rctx.Outputs)
+			}
+
+			// the response is written nested and reads back the same
+			out, err := rctx.ToYAML()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(out) != tests["nested"] {
+				t.Errorf("unexpected response\n%s", out)
+			}
+		})
+	}
+}
+
+func TestParseResourceContextNoInput(t *testing.T) {
+	if _, err := ParseResourceContext([]byte("apiVersion: app.yndd.io/v1\nkind: ResourceContext\n")); err == nil {
+		t.Error("expected an error for a ResourceContext without input")
+	}
+}
@@ -0,0 +1,230 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	ResourceListKind       = "ResourceList"
+	ResourceListAPIVersion = ConfigPrefix + "v1"
+
+	// RoleAnnotation marks the role of a ResourceList item in the
+	// ResourceContext: RoleOrigin or RoleTarget. Items without it become
+	// Input.Items.
+	RoleAnnotation = "app.yndd.io/role"
+	RoleOrigin     = "origin"
+	RoleTarget     = "target"
+)
+
+// errNoOrigin is returned when no ResourceList item is selected as origin.
+var errNoOrigin = errors.New("no origin found in the " + ResourceListKind + " items")
+
+// ResourceListAdapter runs a processor as a kpt/kustomize KRM function: it maps
+// a config.kubernetes.io/v1 ResourceList onto a ResourceContext and the result
+// back onto a ResourceList.
+type ResourceListAdapter struct {
+	// IsOrigin selects the origin among the items. By default the item
+	// annotated with RoleAnnotation RoleOrigin is selected.
+	IsOrigin func(*KubeObject) bool
+	// IsTarget selects the target among the items. By default the item
+	// annotated with RoleAnnotation RoleTarget is selected.
+	IsTarget func(*KubeObject) bool
+}
+
+// IsResourceList tells whether in is a KRM ResourceList rather than a
+// ResourceContext.
+func IsResourceList(in []byte) bool {
+	obj, err := ParseKubeObject(in)
+	if err != nil {
+		return false
+	}
+	return obj.GetKind() == ResourceListKind
+}
+
+// HasRole returns a function that checks if a KubeObject has the given
+// RoleAnnotation.
+func HasRole(role string) func(*KubeObject) bool {
+	return func(o *KubeObject) bool {
+		return o.GetAnnotation(RoleAnnotation) == role
+	}
+}
+
+// ToResourceContext parses a ResourceList and maps it onto a ResourceContext.
+// The functionConfig of the ResourceList becomes Input.FunctionConfig. It also
+// returns the items of the ResourceList, so ToResourceList can keep them; they
+// are also returned when no origin is found among them.
+func (a *ResourceListAdapter) ToResourceContext(in []byte) (*ResourceContext, KubeObjects, error) {
+	rlObj, err := ParseKubeObject(in)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse input bytes: %w", err)
+	}
+	if rlObj.GetKind() != ResourceListKind {
+		return nil, nil, fmt.Errorf("input was of unexpected kind %q; expected %s", rlObj.GetKind(), ResourceListKind)
+	}
+	isOrigin, isTarget := a.IsOrigin, a.IsTarget
+	if isOrigin == nil {
+		isOrigin = HasRole(RoleOrigin)
+	}
+	if isTarget == nil {
+		isTarget = HasRole(RoleTarget)
+	}
+
+	var items KubeObjects
+	itemsSlice, found, err := rlObj.obj.GetNestedSlice("items")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed when tried to get items: %w", err)
+	}
+	if found {
+		objectItems, err := itemsSlice.Elements()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to extract objects from items: %w", err)
+		}
+		for i := range objectItems {
+			items = append(items, asKubeObject(objectItems[i]))
+		}
+	}
+
+	rctx := &ResourceContext{Input: &ResourceContextInputs{}}
+	for _, item := range items {
+		switch {
+		case rctx.Input.Origin == nil && isOrigin(item):
+			rctx.Input.Origin = item
+		case rctx.Input.Target == nil && isTarget(item):
+			rctx.Input.Target = item
+		default:
+			rctx.Input.Items = append(rctx.Input.Items, item)
+		}
+	}
+	if rctx.Input.Origin == nil {
+		return nil, items, fmt.Errorf("%w; annotate it with %s: %s", errNoOrigin, RoleAnnotation, RoleOrigin)
+	}
+	functionConfig, found, err := rlObj.obj.GetNestedMap("functionConfig")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed when tried to get functionConfig: %w", err)
+	}
+	if found {
+		rctx.Input.FunctionConfig = asKubeObject(functionConfig)
+	}
+	return rctx, items, nil
+}
+
+// ToResourceList maps a processed ResourceContext back onto a ResourceList.
+// The items keep their order and are replaced by their version in the
+// ResourceContext inputs; the items the function removed from the inputs are
+// dropped, unless rctx has no inputs at all. Outputs replace the item with the same apiVersion, kind, namespace
+// and name, or are appended like the inputs the function added.
+func (a *ResourceListAdapter) ToResourceList(items KubeObjects, rctx *ResourceContext) ([]byte, error) {
+	var processed KubeObjects
+	if rctx.Input != nil {
+		if rctx.Input.Origin != nil {
+			processed = append(processed, rctx.Input.Origin)
+		}
+		if rctx.Input.Target != nil {
+			processed = append(processed, rctx.Input.Target)
+		}
+		processed = append(processed, rctx.Input.Items...)
+	}
+	processed = append(processed, rctx.Outputs...)
+	latest := map[yaml.ResourceIdentifier]*KubeObject{}
+	for _, obj := range processed {
+		latest[*obj.resourceIdentifier()] = obj
+	}
+
+	itemsSlice := internal.NewSliceVariant()
+	seen := map[yaml.ResourceIdentifier]bool{}
+	for _, item := range items {
+		id := *item.resourceIdentifier()
+		seen[id] = true
+		if obj, ok := latest[id]; ok {
+			item = obj
+		} else if rctx.Input != nil {
+			continue
+		}
+		itemsSlice.Add(item.node())
+	}
+	for _, obj := range processed {
+		id := *obj.resourceIdentifier()
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		itemsSlice.Add(latest[id].node())
+	}
+
+	rlMap := internal.NewMap(nil)
+	rlObj := asKubeObject(rlMap)
+	rlObj.SetAPIVersion(ResourceListAPIVersion)
+	rlObj.SetKind(ResourceListKind)
+	if err := rlMap.SetNestedSlice(itemsSlice, "items"); err != nil {
+		return nil, err
+	}
+	if rctx.Input != nil && rctx.Input.FunctionConfig != nil {
+		if err := rlMap.SetNestedMap(rctx.Input.FunctionConfig.node(), "functionConfig"); err != nil {
+			return nil, err
+		}
+	}
+	if len(rctx.Results) > 0 {
+		resultsSlice := internal.NewSliceVariant()
+		for _, result := range rctx.Results {
+			mv, err := internal.TypedObjectToMapVariant(result)
+			if err != nil {
+				return nil, err
+			}
+			resultsSlice.Add(mv)
+		}
+		if err := rlMap.SetNestedSlice(resultsSlice, "results"); err != nil {
+			return nil, err
+		}
+	}
+	doc := internal.NewDoc([]*yaml.Node{rlMap.Node()}...)
+	return doc.ToYAML()
+}
+
+// Run evaluates p on a ResourceList like RunContext does on a ResourceContext
+// and returns the resulting ResourceList.
+func (a *ResourceListAdapter) Run(ctx context.Context, p ContextProcessor, in []byte, opts ...RunOption) ([]byte, error) {
+	rctx, items, err := a.ToResourceContext(in)
+	if errors.Is(err, errNoOrigin) {
+		// the items are returned unchanged, with the error in the results
+		failed := &ResourceContext{}
+		failed.LogResult(err)
+		out, listErr := a.ToResourceList(items, failed)
+		if listErr != nil {
+			return nil, err
+		}
+		return out, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	rctxIn, err := rctx.ToYAML()
+	if err != nil {
+		return nil, err
+	}
+	rctxOut, runErr := RunContext(ctx, p, rctxIn, opts...)
+	if rctxOut == nil {
+		return nil, runErr
+	}
+	resp, err := ParseResourceContext(rctxOut)
+	if err != nil {
+		return nil, err
+	}
+	out, err := a.ToResourceList(items, resp)
+	if err != nil {
+		return nil, err
+	}
+	return out, runErr
+}
+
+// runInput runs p on in, which is either a ResourceContext or a ResourceList.
+func runInput(ctx context.Context, p ContextProcessor, in []byte, opts ...RunOption) ([]byte, error) {
+	if IsResourceList(in) {
+		return (&ResourceListAdapter{}).Run(ctx, p, in, opts...)
+	}
+	return RunContext(ctx, p, in, opts...)
+}
@@ -0,0 +1,139 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testResourceList = `apiVersion: config.kubernetes.io/v1
+kind: ResourceList
+items:
+- apiVersion: app.yndd.io/v1alpha1
+  kind: App
+  metadata:
+    name: app1
+    annotations:
+      app.yndd.io/role: origin
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: a
+- apiVersion: v1
+  kind: Node
+  metadata:
+    name: b
+functionConfig:
+  apiVersion: v1
+  kind: ConfigMap
+  metadata:
+    name: config
+`
+
+// resourceListItems returns the kind/name of the items of a ResourceList, and
+// its results.
+func resourceListItems(t *testing.T, out []byte) ([]string, []*SubObject) {
+	t.Helper()
+	rl, err := ParseKubeObject(out)
+	if err != nil {
+		t.Fatalf("unable to parse the ResourceList: %v\n%s", err, out)
+	}
+	if rl.GetKind() != ResourceListKind {
+		t.Fatalf("expected a ResourceList, got %s", rl.GetKind())
+	}
+	var items []string
+	for _, item := range rl.GetSlice("items") {
+		obj := asKubeObject(item.obj)
+		items = append(items, obj.GetKind()+"/"+obj.GetName())
+	}
+	return items, rl.GetSlice("results")
+}
+
+func TestResourceListRun(t *testing.T) {
+	tests := map[string]struct {
+		process func(t *testing.T, rctx *ResourceContext)
+		want    []string
+	}{
+		"unchanged": {
+			process: func(t *testing.T, rctx *ResourceContext) {},
+			want:    []string{"App/app1", "Node/a", "Node/b"},
+		},
+		"output": {
+			process: func(t *testing.T, rctx *ResourceContext) {
+				rctx.Outputs = append(rctx.Outputs, testOutput(t))
+			},
+			want: []string{"App/app1", "Node/a", "Node/b", "ConfigMap/cm"},
+		},
+		"deleted item": {
+			process: func(t *testing.T, rctx *ResourceContext) {
+				rctx.Input.Items = rctx.Input.Items[1:]
+			},
+			want: []string{"App/app1", "Node/b"},
+		},
+		"deleted all items": {
+			process: func(t *testing.T, rctx *ResourceContext) {
+				rctx.Input.Items = nil
+			},
+			want: []string{"App/app1"},
+		},
+		"renamed item": {
+			process: func(t *testing.T, rctx *ResourceContext) {
+				rctx.Input.Items[0].SetName("c")
+			},
+			want: []string{"App/app1", "Node/b", "Node/c"},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+				tc.process(t, rctx)
+				return true, nil
+			})
+			out, err := runInput(context.Background(), p, []byte(testResourceList))
+			if err != nil {
+				t.Fatal(err)
+			}
+			items, results := resourceListItems(t, out)
+			if !reflect.DeepEqual(items, tc.want) {
+				t.Errorf("expected items %v, got %v", tc.want, items)
+			}
+			if len(results) > 0 {
+				t.Errorf("expected no results, got %d", len(results))
+			}
+			if !strings.Contains(string(out), "functionConfig:\n  apiVersion: v1\n  kind: ConfigMap") {
+				t.Errorf("expected the functionConfig to be kept\n%s", out)
+			}
+		})
+	}
+}
+
+func TestResourceListRunWithoutOrigin(t *testing.T) {
+	in := strings.Replace(testResourceList, "app.yndd.io/role: origin", "app.yndd.io/role: none", 1)
+	called := false
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		called = true
+		return true, nil
+	})
+	out, err := runInput(context.Background(), p, []byte(in))
+	if !errors.Is(err, errNoOrigin) {
+		t.Fatalf("expected a missing origin error, got %v", err)
+	}
+	if called {
+		t.Error("expected the processor not to run")
+	}
+	items, results := resourceListItems(t, out)
+	if want := []string{"App/app1", "Node/a", "Node/b"}; !reflect.DeepEqual(items, want) {
+		t.Errorf("expected items %v, got %v", want, items)
+	}
+	if len(results) != 1 {
+		t.Fatalf("expected a single result, got %d\n%s", len(results), out)
+	}
+	if severity := results[0].NestedStringOrDie("severity"); severity != string(Error) {
+		t.Errorf("expected an error result, got %s", severity)
+	}
+	if message := results[0].NestedStringOrDie("message"); !strings.Contains(message, RoleAnnotation+": "+RoleOrigin) {
+		t.Errorf("expected the result to tell how to mark the origin, got %q", message)
+	}
+}
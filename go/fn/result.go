@@ -19,6 +19,9 @@ const (
 	Info Severity = "info"
 )
 
+// StackTraceTag is the Result tag holding the stack trace of a recovered panic.
+const StackTraceTag = "stacktrace"
+
 // Result defines a validation result
 type Result struct {
 	// Message is a human readable message. This field is required.
@@ -31,11 +34,26 @@ type Result struct {
 	// Required fields: apiVersion, kind, name.
 	ResourceRef *yaml.ResourceIdentifier `yaml:"resourceRef,omitempty" json:"resourceRef,omitempty"`
 
+	// Field is a reference to the field in a resource this result refers to
+	Field *Field `yaml:"field,omitempty" json:"field,omitempty"`
+
 	// Tags is an unstructured key value map stored with a result that may be set
 	// by external tools to store and retrieve arbitrary metadata
 	Tags map[string]string `yaml:"tags,omitempty" json:"tags,omitempty"`
 }
 
+// Field references a field in a resource
+type Field struct {
+	// Path is the field path. This field is required.
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// CurrentValue is the current field value
+	CurrentValue interface{} `yaml:"currentValue,omitempty" json:"currentValue,omitempty"`
+
+	// ProposedValue is the proposed value of the field to fix an issue.
+	ProposedValue interface{} `yaml:"proposedValue,omitempty" json:"proposedValue,omitempty"`
+}
+
 func (i Result) Error() string {
 	return (i).String()
 }
@@ -69,6 +87,10 @@ func (i Result) String() string {
 		formatString += " %s"
 		list = append(list, strings.Join(idStringList, "/"))
 	}
+	if i.Field != nil && i.Field.Path != "" {
+		formatString += " %s"
+		list = append(list, i.Field.Path)
+	}
 	formatString += ": %s"
 	list = append(list, i.Message)
 	return fmt.Sprintf(formatString, list...)
@@ -95,3 +117,22 @@ func GeneralResult(msg string, severity Severity) *Result {
 		Severity: severity,
 	}
 }
+
+// ConfigObjectResult returns a Result that refers to obj.
+func ConfigObjectResult(msg string, obj *KubeObject, severity Severity) *Result {
+	return &Result{
+		Message:     msg,
+		Severity:    severity,
+		ResourceRef: obj.resourceIdentifier(),
+	}
+}
+
+// ConfigFieldResult returns a Result that refers to the field at path in obj.
+func ConfigFieldResult(msg string, obj *KubeObject, field Field, severity Severity) *Result {
+	return &Result{
+		Message:     msg,
+		Severity:    severity,
+		ResourceRef: obj.resourceIdentifier(),
+		Field:       &field,
+	}
+}
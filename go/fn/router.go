@@ -0,0 +1,96 @@
+package fn
+
+import (
+	"context"
+	"fmt"
+)
+
+// Router is a ResourceContextProcessor that dispatches to the processor
+// registered for the GVK of Input.Origin, so one function can handle several
+// origin kinds. Routes without wildcards win over routes with wildcards; among
+// those the route with the fewest wildcards wins, then the first registered. An
+// empty group and version count as two wildcards and an empty kind as one, as
+// they match any apiVersion or kind.
+// The fallback processor handles origins no route matches; without one an
+// error Result is reported.
+type Router struct {
+	routes   []route
+	fallback ContextProcessor
+}
+
+type route struct {
+	GroupVersionKind
+	processor ContextProcessor
+}
+
+// NewRouter returns an empty Router.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers p for origins of the given group, version and kind. Any of
+// them can be Wildcard. As in KubeObject.IsGVK, the core group is the empty
+// group.
+func (r *Router) Handle(group, version, kind string, p ResourceContextProcessor) *Router {
+	return r.HandleContext(group, version, kind, AsContextProcessor(p))
+}
+
+// HandleFunc registers f for origins of the given group, version and kind.
+func (r *Router) HandleFunc(group, version, kind string, f ResourceContextProcessorFunc) *Router {
+	return r.Handle(group, version, kind, f)
+}
+
+// HandleContext registers the context aware p for origins of the given group,
+// version and kind.
+func (r *Router) HandleContext(group, version, kind string, p ContextProcessor) *Router {
+	r.routes = append(r.routes, route{
+		GroupVersionKind: GroupVersionKind{Group: group, Version: version, Kind: kind},
+		processor:        p,
+	})
+	return r
+}
+
+// Fallback registers p for origins no route matches.
+func (r *Router) Fallback(p ResourceContextProcessor) *Router {
+	r.fallback = AsContextProcessor(p)
+	return r
+}
+
+func (r *Router) Process(rc *ResourceContext) (bool, error) {
+	return r.ProcessContext(context.Background(), rc)
+}
+
+func (r *Router) ProcessContext(ctx context.Context, rc *ResourceContext) (bool, error) {
+	if rc.Input == nil || rc.Input.Origin == nil {
+		result := GeneralResult("router: the ResourceContext has no origin to route on", Error)
+		rc.LogResult(result)
+		return false, result
+	}
+	if p := r.match(rc.Input.Origin); p != nil {
+		return p.ProcessContext(ctx, rc)
+	}
+	if r.fallback != nil {
+		return r.fallback.ProcessContext(ctx, rc)
+	}
+	result := GeneralResult(fmt.Sprintf("router: no processor registered for origin apiVersion=%s, kind=%s",
+		rc.Input.Origin.GetAPIVersion(), rc.Input.Origin.GetKind()), Error)
+	result.ResourceRef = rc.Input.Origin.resourceIdentifier()
+	rc.LogResult(result)
+	return false, result
+}
+
+// match returns the processor of the most specific route that matches obj.
+func (r *Router) match(obj *KubeObject) ContextProcessor {
+	var best ContextProcessor
+	bestWildcards := -1
+	for _, rt := range r.routes {
+		if !rt.Matches(obj) {
+			continue
+		}
+		wildcards := rt.wildcards()
+		if best == nil || wildcards < bestWildcards {
+			best, bestWildcards = rt.processor, wildcards
+		}
+	}
+	return best
+}
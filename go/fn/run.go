@@ -1,30 +1,149 @@
 package fn
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
+	"runtime/debug"
+	"time"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
 )
 
-// input is a interface to pass a ResourceContextProcessor implementation
-// AsMain also gets stdin
-func AsMain(input interface{}) error {
+// RunOption configures a single invocation of Run, RunContext or AsMain.
+type RunOption func(*runOptions)
+
+type runOptions struct {
+	timeout        time.Duration
+	deadline       time.Time
+	tracerProvider trace.TracerProvider
+	meterProvider  metric.MeterProvider
+	observers      []RunObserver
+	stackTrace     bool
+	spec           *FunctionSpec
+	recorder       *recorder
+	keepFormat     bool
+	// format is the Format of the input, set by RunContext when keepFormat is.
+	format *Format
+}
+
+func newRunOptions(opts ...RunOption) *runOptions {
+	o := &runOptions{recorder: recorderFromEnv()}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithTimeout bounds the time the processor may take. When the timeout expires
+// an error Result is returned instead of waiting for the processor, which keeps
+// running in the background until it returns, see RunContext.
+func WithTimeout(d time.Duration) RunOption {
+	return func(o *runOptions) {
+		o.timeout = d
+	}
+}
+
+// WithDeadline sets an absolute deadline for the processor. When the deadline
+// expires an error Result is returned instead of waiting for the processor.
+func WithDeadline(t time.Time) RunOption {
+	return func(o *runOptions) {
+		o.deadline = t
+	}
+}
+
+// WithPanicStackTrace adds the stack trace of a recovered processor panic to the
+// Tags of its Result and logs it to stderr.
+func WithPanicStackTrace() RunOption {
+	return func(o *runOptions) {
+		o.stackTrace = true
+	}
+}
+
+// WithPreserveFormat writes the response in the layout of the input instead of
+// sorting and reformatting it: the fields keep their order, style and comments,
+// and the sequence indentation and document start of the input are kept, see
+// ResourceContext.ToYAMLWithFormat. An input that the processor doesn't change
+// is returned as it was read.
+func WithPreserveFormat() RunOption {
+	return func(o *runOptions) {
+		o.keepFormat = true
+	}
+}
+
+// RunObserver is notified when a run completes. rctx is the ResourceContext
+// returned to the caller; it is nil when the input could not be parsed.
+type RunObserver interface {
+	ObserveRun(rctx *ResourceContext, duration time.Duration, err error)
+}
+
+// RunObserverFunc converts a compatible function to a RunObserver.
+type RunObserverFunc func(rctx *ResourceContext, duration time.Duration, err error)
+
+func (f RunObserverFunc) ObserveRun(rctx *ResourceContext, duration time.Duration, err error) {
+	f(rctx, duration, err)
+}
+
+// WithRunObserver adds an observer that is notified when the run completes.
+func WithRunObserver(observer RunObserver) RunOption {
+	return func(o *runOptions) {
+		o.observers = append(o.observers, observer)
+	}
+}
+
+// context derives the invocation context from parent and the configured
+// timeout and deadline. The earliest of both wins.
+func (o *runOptions) context(parent context.Context) (context.Context, context.CancelFunc) {
+	deadline := o.deadline
+	if o.timeout > 0 {
+		if d := time.Now().Add(o.timeout); deadline.IsZero() || d.Before(deadline) {
+			deadline = d
+		}
+	}
+	if deadline.IsZero() {
+		return context.WithCancel(parent)
+	}
+	return context.WithDeadline(parent, deadline)
+}
+
+// input is a interface to pass a ResourceContextProcessor or ContextProcessor implementation
+// AsMain also gets stdin. SIGINT and SIGTERM cancel the context passed to a ContextProcessor.
+// Built with GOOS=wasip1 GOARCH=wasm, AsMain runs the function as a WASI module,
+// e.g. for a wasm.Runner; the module receives no signals.
+func AsMain(input interface{}, opts ...RunOption) error {
 	err := func() error {
-		// ResourceContextProcessor interface
-		var p ResourceContextProcessor
+		// ContextProcessor interface
+		var p ContextProcessor
 		switch input := input.(type) {
+		// implementation of the ContextProcessor interface
+		case ContextProcessorFunc:
+			p = input
 		// implementation of the ResourceContextProcessor interface
 		case ResourceContextProcessorFunc:
+			p = AsContextProcessor(input)
+		case ContextProcessor:
 			p = input
+		case ResourceContextProcessor:
+			p = AsContextProcessor(input)
 		default:
 			return fmt.Errorf("unknown input type %T", input)
 		}
 
+		if describeRequested() {
+			return describe(os.Stdout, newRunOptions(opts...).spec)
+		}
+
+		ctx, stop := signalContext(context.Background())
+		defer stop()
+
 		in, err := ioutil.ReadAll(os.Stdin)
 		if err != nil {
 			return fmt.Errorf("unable to read from stdin: %v", err)
 		}
-		out, err := Run(p, in)
+		out, err := runInput(ctx, p, in, opts...)
 		// If there is an error, we don't return the error immediately.
 		// We write out to stdout before returning any error.
 		_, outErr := os.Stdout.Write(out)
@@ -41,7 +160,30 @@ func AsMain(input interface{}) error {
 
 // Run evaluates the function. input must be a ResourceContext in yaml format. A
 // New Managed Resource will be returned
-func Run(p ResourceContextProcessor, input []byte) (out []byte, err error) {
+func Run(p ResourceContextProcessor, input []byte, opts ...RunOption) (out []byte, err error) {
+	return RunContext(context.Background(), AsContextProcessor(p), input, opts...)
+}
+
+// processResult is the outcome of a single ProcessContext call.
+type processResult struct {
+	success bool
+	err     error
+	// panicked is true when the processor panicked with value panicValue.
+	panicked   bool
+	panicValue interface{}
+	stack      []byte
+}
+
+// RunContext evaluates the function like Run, but passes ctx to the processor.
+// If ctx is done or the configured timeout expires before the processor returns,
+// the original input is returned with an error Result and the processor's
+// partial outputs are discarded.
+//
+// A processor that is interrupted is not stopped: its goroutine keeps running
+// until the processor returns. Processors should return when their context is
+// done; one that ignores it, e.g. a Processor wrapped by AsContextProcessor,
+// leaks a goroutine on every interrupted run.
+func RunContext(ctx context.Context, p ContextProcessor, input []byte, opts ...RunOption) (out []byte, err error) {
 	/*
 		obj := &unstructured.Unstructured{}
 
@@ -53,47 +195,228 @@ func Run(p ResourceContextProcessor, input []byte) (out []byte, err error) {
 		}
 		fmt.Printf("Managed Resource: \ngvk: \n %v\nobj: \n %v\n ", gvk, obj)
 	*/
-	rctx, err := ParseResourceContext(input)
+	o := newRunOptions(opts...)
+	tel := newTelemetry(o)
+	ctx, endRun := tel.startRun(ctx)
+	start := time.Now()
+	var rctx *ResourceContext
+	defer func() {
+		endRun(rctx, err)
+		for _, observer := range o.observers {
+			observer.ObserveRun(rctx, time.Since(start), err)
+		}
+		if o.recorder != nil {
+			o.recorder.record(input, out, err)
+		}
+	}()
+
+	_, endParse := tel.startStage(ctx, StageParse)
+	rctx, err = ParseResourceContext(input)
+	endParse(err)
 	if err != nil {
 		return nil, err
 	}
+	if o.keepFormat {
+		f := DetectFormat(input)
+		o.format = &f
+	}
 
-	defer func() {
+	if o.spec != nil {
+		if results := o.spec.checkInputs(rctx); len(results) > 0 {
+			rctx.LogResult(results)
+			return respond(ctx, tel, o, input, rctx, results)
+		}
+	}
+
+	ctx, cancel := o.context(ctx)
+	defer cancel()
+
+	// the processor runs in its own goroutine so an expired context can return
+	// without waiting for it. The goroutine only uses its own arguments, as it
+	// may outlive the run.
+	processCtx, endProcess := tel.startStage(ctx, StageProcess)
+	done := make(chan processResult, 1)
+	go func(ctx context.Context, rctx *ResourceContext) {
+		// the result is sent from a deferred call, so the run also ends when
+		// the processor panics or calls runtime.Goexit
+		res := processResult{err: fmt.Errorf("error: function exited without returning")}
+		defer func() {
+			if v := recover(); v != nil {
+				res = processResult{panicked: true, panicValue: v, stack: debug.Stack()}
+			}
+			done <- res
+		}()
+		success, fnErr := p.ProcessContext(ctx, rctx)
+		res = processResult{success: success, err: fnErr}
+	}(processCtx, rctx)
+
+	var res processResult
+	select {
+	case res = <-done:
+	case <-ctx.Done():
+		endProcess(ctx.Err())
+		interrupted, interruptErr := interruptedContext(input, ctx.Err())
+		if interrupted == nil {
+			return nil, interruptErr
+		}
+		rctx = interrupted
+		return respond(ctx, tel, o, input, interrupted, interruptErr)
+	}
+
+	if res.panicked {
 		// if we run into a panic, we still need to log the error to Results,
-		// and return the ResourceList and error.
-		v := recover()
-		if v != nil {
-			switch t := v.(type) {
-			case errKubeObjectFields:
-				err = &t
-			case *errKubeObjectFields:
-				err = t
-			case errSubObjectFields:
-				err = &t
-			case *errSubObjectFields:
-				err = t
-			case errResultEnd:
-				err = &t
-			case *errResultEnd:
-				err = t
-			default:
-				panic(v)
+		// and return the ResourceList and error. The outputs built so far are
+		// kept.
+		switch t := res.panicValue.(type) {
+		case errKubeObjectFields:
+			err = &t
+		case *errKubeObjectFields:
+			err = t
+		case errSubObjectFields:
+			err = &t
+		case *errSubObjectFields:
+			err = t
+		case errResultEnd:
+			return endEarly(ctx, tel, o, input, rctx, &t, endProcess)
+		case *errResultEnd:
+			return endEarly(ctx, tel, o, input, rctx, t, endProcess)
+		default:
+			err = &errProcessPanic{value: res.panicValue, stack: res.stack}
+		}
+		endProcess(err)
+		rctx.LogResult(panicResult(rctx, err, o.stackTrace))
+		return respond(ctx, tel, o, input, rctx, err)
+	}
+
+	fnErr := res.err
+	if fnErr == nil && !res.success {
+		fnErr = fmt.Errorf("error: function failure")
+	}
+	endProcess(fnErr)
+	return respond(ctx, tel, o, input, rctx, fnErr)
+}
+
+// endEarly responds to a processor that terminated with Exit, Fail or Skip.
+// Only Fail makes the run return an error.
+func endEarly(ctx context.Context, tel *telemetry, o *runOptions, input []byte, rctx *ResourceContext, e *errResultEnd, endProcess func(error)) ([]byte, error) {
+	var err error
+	if e.severity == Error {
+		err = e
+	}
+	endProcess(err)
+	if e.skip {
+		rctx.Outputs = nil
+	}
+	rctx.LogResult(e.result(rctx))
+	return respond(ctx, tel, o, input, rctx, err)
+}
+
+// panicResult converts the error recovered from a processor panic to a Result.
+// Panics with an arbitrary value are always logged to stderr; their stack trace
+// is added to the Result Tags and stderr when stackTrace is set.
+func panicResult(rctx *ResourceContext, err error, stackTrace bool) error {
+	p, ok := err.(*errProcessPanic)
+	if !ok {
+		return err
+	}
+	result := ErrorResult(p)
+	if rctx.Input != nil && rctx.Input.Origin != nil {
+		result.ResourceRef = rctx.Input.Origin.resourceIdentifier()
+	}
+	if stackTrace {
+		result.Tags = map[string]string{StackTraceTag: string(p.stack)}
+		Logf("%v\n%s", p, p.stack)
+	} else {
+		Logf("%v\n", p)
+	}
+	return result
+}
+
+// respond serializes rctx as the response of a run that failed with fnErr, if
+// any. Outputs not declared by the FunctionSpec are dropped and returned as
+// error Results. A ResourceContext that can't be serialized, e.g. because the
+// processor left it in an inconsistent state, is replaced by the original input
+// with the Results reported so far, so the caller always gets a parseable
+// response. fnErr is returned first, so the errors of the response itself only
+// show in its Results when the run failed already.
+func respond(ctx context.Context, tel *telemetry, o *runOptions, input []byte, rctx *ResourceContext, fnErr error) ([]byte, error) {
+	err := fnErr
+	if o.spec != nil {
+		if results := o.spec.enforceOutputs(rctx); len(results) > 0 {
+			rctx.LogResult(results)
+			if err == nil {
+				err = results
 			}
-			rctx.LogResult(err)
-			out, _ = rctx.ToYAML()
 		}
-	}()
+	}
+	out, yamlErr := tel.toYAML(ctx, rctx, o.format)
+	if yamlErr == nil {
+		return out, err
+	}
+	yamlErr = fmt.Errorf("unable to serialize the function response: %w", yamlErr)
+	if err == nil {
+		err = yamlErr
+	}
+	fallback, parseErr := ParseResourceContext(input)
+	if parseErr != nil {
+		return nil, err
+	}
+	fallback.Results = append(fallback.Results, rctx.Results...)
+	fallback.LogResult(yamlErr)
+	out, fallbackErr := tel.toYAML(ctx, fallback, o.format)
+	if fallbackErr != nil {
+		return nil, err
+	}
+	return out, err
+}
+
+// describe writes spec in yaml to w.
+func describe(w io.Writer, spec *FunctionSpec) error {
+	if spec == nil {
+		return fmt.Errorf("the function has no FunctionSpec to describe")
+	}
+	out, err := spec.ToYAML()
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
 
-	success, fnErr := p.Process(rctx)
-	out, yamlErr := rctx.ToYAML()
-	if yamlErr != nil {
-		return out, yamlErr
+// interruptedContext builds the response for a processor that did not return
+// before its context was done. The processor may still be mutating its
+// ResourceContext, so the response is rebuilt from the original input.
+func interruptedContext(input []byte, cause error) (*ResourceContext, error) {
+	rctx, err := ParseResourceContext(input)
+	if err != nil {
+		return nil, err
 	}
-	if fnErr != nil {
-		return out, fnErr
+	err = &errProcessInterrupted{cause: cause}
+	result := ErrorResult(err)
+	if rctx.Input.Origin != nil {
+		result.ResourceRef = rctx.Input.Origin.resourceIdentifier()
 	}
-	if !success {
-		return out, fmt.Errorf("error: function failure")
+	rctx.Outputs = nil
+	rctx.LogResult(result)
+	return rctx, err
+}
+
+// toYAML serializes the response of a run in the Format f, or sorted like
+// ResourceContext.ToYAML when f is nil, and records its outputs and results. A
+// panic during serialization is returned as an error.
+func (t *telemetry) toYAML(ctx context.Context, rctx *ResourceContext, f *Format) (out []byte, err error) {
+	_, endToYAML := t.startStage(ctx, StageToYAML)
+	defer func() {
+		if v := recover(); v != nil {
+			out, err = nil, fmt.Errorf("panic: %v", v)
+		}
+		endToYAML(err)
+		if err == nil {
+			t.recordResponse(ctx, rctx)
+		}
+	}()
+	if f != nil {
+		return rctx.ToYAMLWithFormat(*f)
 	}
-	return out, nil
+	return rctx.ToYAML()
 }
@@ -0,0 +1,257 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testInput = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+`
+
+// testOutput returns a new output object for a processor to add.
+func testOutput(t *testing.T) *KubeObject {
+	obj, err := ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+// runWithin runs RunContext and fails the test if it doesn't return in time.
+func runWithin(t *testing.T, ctx context.Context, p ContextProcessor, opts ...RunOption) ([]byte, error) {
+	t.Helper()
+	type result struct {
+		out []byte
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		out, err := RunContext(ctx, p, []byte(testInput), opts...)
+		ch <- result{out, err}
+	}()
+	select {
+	case r := <-ch:
+		return r.out, r.err
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunContext did not return")
+		return nil, nil
+	}
+}
+
+func TestRunContextTimeout(t *testing.T) {
+	release := make(chan struct{})
+	finished := make(chan struct{})
+	output := testOutput(t)
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		defer close(finished)
+		<-release
+		// the processor keeps using its context after the run was interrupted
+		rctx.Outputs = append(rctx.Outputs, output)
+		return true, nil
+	})
+
+	out, err := runWithin(t, context.Background(), p, WithTimeout(10*time.Millisecond))
+	close(release)
+	<-finished
+
+	var interrupted *errProcessInterrupted
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("expected an interrupted error, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the error to wrap the deadline, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "deadline exceeded") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+	rctx, parseErr := ParseResourceContext(out)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	if len(rctx.Outputs) != 0 {
+		t.Errorf("expected the outputs of the interrupted processor to be discarded, got %d", len(rctx.Outputs))
+	}
+	if len(rctx.Results) != 1 || rctx.Results[0].Severity != Error {
+		t.Errorf("expected one error Result, got %v", rctx.Results)
+	}
+}
+
+func TestRunContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	started := make(chan struct{})
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		close(started)
+		<-ctx.Done()
+		return false, ctx.Err()
+	})
+	go func() {
+		<-started
+		cancel()
+	}()
+
+	out, err := runWithin(t, ctx, p)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected a canceled error, got %v", err)
+	}
+	if _, parseErr := ParseResourceContext(out); parseErr != nil {
+		t.Fatal(parseErr)
+	}
+}
+
+func TestRunContextPanic(t *testing.T) {
+	output := testOutput(t)
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		rctx.Outputs = append(rctx.Outputs, output)
+		panic("boom")
+	})
+
+	out, err := runWithin(t, context.Background(), p, WithTimeout(time.Second))
+	var panicErr *errProcessPanic
+	if !errors.As(err, &panicErr) {
+		t.Fatalf("expected a panic error, got %v", err)
+	}
+	rctx, parseErr := ParseResourceContext(out)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	if len(rctx.Outputs) != 1 {
+		t.Errorf("expected the outputs built before the panic to be kept, got %d", len(rctx.Outputs))
+	}
+}
+
+func TestRunContextPanicValues(t *testing.T) {
+	tests := map[string]struct {
+		panic      func(rctx *ResourceContext)
+		stackTrace bool
+		wantErr    interface{}
+		wantMsg    string
+	}{
+		"KubeObject field error": {
+			panic: func(rctx *ResourceContext) {
+				panic(errKubeObjectFields{obj: rctx.Input.Origin, fields: []string{"spec", "mtu"}})
+			},
+			wantErr: &errKubeObjectFields{},
+			wantMsg: "kind=App, Name=app1) has unmatched field type: `spec/mtu",
+		},
+		"SubObject field error": {
+			panic: func(rctx *ResourceContext) {
+				rctx.Input.Origin.NestedStringOrDie("metadata")
+			},
+			wantErr: &errSubObjectFields{},
+			wantMsg: "SubObject has unmatched field type: `metadata",
+		},
+		"arbitrary value": {
+			panic: func(rctx *ResourceContext) {
+				panic(42)
+			},
+			wantErr: &errProcessPanic{},
+			wantMsg: "function panicked: 42",
+		},
+		"nil dereference with stack trace": {
+			panic: func(rctx *ResourceContext) {
+				var obj *KubeObject
+				_ = obj.SubObject
+			},
+			stackTrace: true,
+			wantErr:    &errProcessPanic{},
+			wantMsg:    "function panicked: runtime error: invalid memory address or nil pointer dereference",
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			output := testOutput(t)
+			p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+				rctx.Outputs = append(rctx.Outputs, output)
+				tc.panic(rctx)
+				return true, nil
+			})
+			opts := []RunOption{}
+			if tc.stackTrace {
+				opts = append(opts, WithPanicStackTrace())
+			}
+
+			out, err := runWithin(t, context.Background(), p, opts...)
+			if err == nil || reflect.TypeOf(err) != reflect.TypeOf(tc.wantErr) {
+				t.Fatalf("expected an error of type %T, got %T: %v", tc.wantErr, err, err)
+			}
+			rctx, parseErr := ParseResourceContext(out)
+			if parseErr != nil {
+				t.Fatal(parseErr)
+			}
+			if len(rctx.Outputs) != 1 {
+				t.Errorf("expected the outputs built before the panic to be kept, got %d", len(rctx.Outputs))
+			}
+			if len(rctx.Results) != 1 {
+				t.Fatalf("expected one Result, got %v", rctx.Results)
+			}
+			result := rctx.Results[0]
+			if result.Severity != Error || !strings.Contains(result.Message, tc.wantMsg) {
+				t.Errorf("expected an error Result with %q, got %s: %q", tc.wantMsg, result.Severity, result.Message)
+			}
+			if _, ok := tc.wantErr.(*errProcessPanic); ok && (result.ResourceRef == nil || result.ResourceRef.Name != "app1") {
+				t.Errorf("expected the Result to reference the origin, got %v", result.ResourceRef)
+			}
+			if stack := result.Tags[StackTraceTag]; tc.stackTrace != strings.Contains(stack, "goroutine") {
+				t.Errorf("expected a stack trace in the Tags: %v, got %q", tc.stackTrace, stack)
+			}
+		})
+	}
+}
+
+func TestRunContextGoexit(t *testing.T) {
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		runtime.Goexit()
+		return true, nil
+	})
+
+	// without a deadline, the run must still end
+	_, err := runWithin(t, context.Background(), p)
+	if err == nil {
+		t.Fatal("expected an error for a processor that exited without returning")
+	}
+}
+
+func TestErrProcessInterruptedWrappedDeadline(t *testing.T) {
+	err := &errProcessInterrupted{cause: fmt.Errorf("otel: %w", context.DeadlineExceeded)}
+	if !strings.Contains(err.Error(), "deadline exceeded before processing finished") {
+		t.Errorf("unexpected error message %q", err.Error())
+	}
+}
+
+func TestRunContextProcessorErrorBeforeSpecViolation(t *testing.T) {
+	fnErr := errors.New("processor failed")
+	output := testOutput(t)
+	p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+		rctx.Outputs = append(rctx.Outputs, output)
+		return false, fnErr
+	})
+	spec := &FunctionSpec{Name: "test", Outputs: []GroupVersionKind{{Version: "v1", Kind: "Secret"}}}
+
+	out, err := runWithin(t, context.Background(), p, WithFunctionSpec(spec))
+	if !errors.Is(err, fnErr) {
+		t.Fatalf("expected the processor error, got %v", err)
+	}
+	rctx, parseErr := ParseResourceContext(out)
+	if parseErr != nil {
+		t.Fatal(parseErr)
+	}
+	if len(rctx.Outputs) != 0 {
+		t.Errorf("expected the undeclared output to be dropped, got %d", len(rctx.Outputs))
+	}
+	if len(rctx.Results) != 1 || !strings.Contains(rctx.Results[0].Message, "does not declare output") {
+		t.Errorf("expected the spec violation in the Results, got %v", rctx.Results)
+	}
+}
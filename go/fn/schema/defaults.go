@@ -0,0 +1,203 @@
+package schema
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// Default sets the fields of obj that are missing to the default: value of
+// their schema, as the API server does for the structural schemas of CRDs:
+// defaults apply to the missing fields of objects that exist, including the
+// objects in lists and maps and the objects that are defaulted themselves.
+// Every defaulted field is reported as a Warning Result, since the default
+// filled a field that was not set.
+func (v *Validator) Default(obj *fn.KubeObject) (fn.Results, error) {
+	s, ok := v.Schema(obj)
+	if !ok {
+		return nil, nil
+	}
+	var value interface{}
+	if err := obj.As(&value); err != nil {
+		return nil, err
+	}
+	d := &defaulter{obj: obj}
+	if err := d.apply(s, &obj.SubObject, nil, value, ""); err != nil {
+		return d.results, err
+	}
+	return d.results, nil
+}
+
+// DefaultOrigin defaults Input.Origin of rctx, see Default.
+func (v *Validator) DefaultOrigin(rctx *fn.ResourceContext) (fn.Results, error) {
+	if rctx.Input == nil || rctx.Input.Origin == nil {
+		return nil, nil
+	}
+	return v.Default(rctx.Input.Origin)
+}
+
+// DefaultOutputs defaults the outputs of rctx, see Default.
+func (v *Validator) DefaultOutputs(rctx *fn.ResourceContext) (fn.Results, error) {
+	var results fn.Results
+	for _, output := range rctx.Outputs {
+		r, err := v.Default(output)
+		results = append(results, r...)
+		if err != nil {
+			return results, err
+		}
+	}
+	return results, nil
+}
+
+// Defaulting returns a processor that defaults the origin before it calls p and
+// the outputs after p returns, so a function behaves the same on an origin read
+// from a file as on one read from the API server. The defaulted fields are
+// reported as Results.
+func (v *Validator) Defaulting(p fn.ContextProcessor) fn.ContextProcessor {
+	return fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		results, err := v.DefaultOrigin(rctx)
+		if len(results) > 0 {
+			rctx.LogResult(results)
+		}
+		if err != nil {
+			return false, err
+		}
+		success, err := p.ProcessContext(ctx, rctx)
+		if err != nil || !success {
+			return success, err
+		}
+		results, err = v.DefaultOutputs(rctx)
+		if len(results) > 0 {
+			rctx.LogResult(results)
+		}
+		if err != nil {
+			return false, err
+		}
+		return true, nil
+	})
+}
+
+type defaulter struct {
+	obj     *fn.KubeObject
+	results fn.Results
+}
+
+// apply defaults value, found at fields in so and at path in the object.
+func (d *defaulter) apply(s *Schema, so *fn.SubObject, fields []string, value interface{}, path string) error {
+	s = s.deref()
+	if s == nil {
+		return nil
+	}
+	for _, sub := range s.AllOf {
+		if err := d.apply(sub, so, fields, value, path); err != nil {
+			return err
+		}
+	}
+	switch value := value.(type) {
+	case map[string]interface{}:
+		for _, name := range sortedProperties(s) {
+			prop := s.Properties[name].deref()
+			if _, ok := value[name]; ok || prop == nil || prop.Default == nil {
+				continue
+			}
+			def := defaultValue(prop, prop.Default)
+			p := fieldPath(path, name)
+			if err := so.SetNestedField(def, append(fields[:len(fields):len(fields)], name)...); err != nil {
+				return fmt.Errorf("unable to default %s: %w", p, err)
+			}
+			value[name] = deepCopy(prop.Default)
+			d.results = append(d.results, fn.ConfigFieldResult(fmt.Sprintf("defaulted to %s", jsonString(prop.Default)), d.obj,
+				fn.Field{Path: p, ProposedValue: prop.Default}, fn.Warning))
+		}
+		keys := make([]string, 0, len(value))
+		for k := range value {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			val := value[k]
+			var sub *Schema
+			if prop, ok := s.Properties[k]; ok {
+				sub = prop
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				sub = s.AdditionalProperties.Schema
+			}
+			if sub == nil {
+				continue
+			}
+			if err := d.apply(sub, so, append(fields[:len(fields):len(fields)], k), val, fieldPath(path, k)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		// only the objects in a list have fields to default
+		if s.Items == nil || len(fields) == 0 || !allObjects(value) {
+			return nil
+		}
+		items, _, err := so.NestedSlice(fields...)
+		if err != nil {
+			return err
+		}
+		for i, item := range value {
+			if i >= len(items) {
+				break
+			}
+			if err := d.apply(s.Items, items[i], nil, item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// defaultValue converts the default of s, as decoded from json, to the value
+// that is set, so integers are not written as floats.
+func defaultValue(s *Schema, def interface{}) interface{} {
+	if f, ok := def.(float64); ok && (s.Type == "integer" || s.XIntOrString) && f == math.Trunc(f) {
+		return int64(f)
+	}
+	return def
+}
+
+func allObjects(values []interface{}) bool {
+	for _, v := range values {
+		if _, ok := v.(map[string]interface{}); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedProperties(s *Schema) []string {
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
+
+// deepCopy copies a value decoded from json.
+func deepCopy(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var c interface{}
+	if err := json.Unmarshal(b, &c); err != nil {
+		return v
+	}
+	return c
+}
@@ -0,0 +1,105 @@
+package schema
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+func TestDefault(t *testing.T) {
+	tests := map[string]struct {
+		spec string
+		// want is the spec once defaulted
+		want    string
+		results []string
+	}{
+		"top level": {
+			spec:    `{image: nginx}`,
+			want:    `{image: nginx, mode: active, replicas: 1, resources: {cpu: 100m}}`,
+			results: []string{`spec.mode: defaulted to "active"`, "spec.replicas: defaulted to 1", "spec.resources: defaulted to {}", `spec.resources.cpu: defaulted to "100m"`},
+		},
+		"set fields are kept": {
+			spec: `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}}`,
+			want: `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}}`,
+		},
+		"nested object": {
+			spec:    `{image: nginx, mode: standby, replicas: 3, resources: {memory: 1Gi}}`,
+			want:    `{image: nginx, mode: standby, replicas: 3, resources: {cpu: 100m, memory: 1Gi}}`,
+			results: []string{`spec.resources.cpu: defaulted to "100m"`},
+		},
+		"objects in arrays": {
+			spec:    `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}, ports: [{port: 53, protocol: UDP}, {port: 80}]}`,
+			want:    `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}, ports: [{port: 53, protocol: UDP}, {port: 80, protocol: TCP}]}`,
+			results: []string{`spec.ports[1].protocol: defaulted to "TCP"`},
+		},
+		"scalar arrays": {
+			spec: `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}, tags: [a, b]}`,
+			want: `{image: nginx, mode: standby, replicas: 3, resources: {cpu: "1"}, tags: [a, b]}`,
+		},
+	}
+	v := testValidator(t)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			obj := testApp(t, tc.spec)
+			results, err := v.Default(obj)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, result := range results {
+				if result.Severity != fn.Warning || result.ResourceRef == nil || result.ResourceRef.Name != "app1" {
+					t.Errorf("expected a Warning for the App, got %v", result)
+				}
+				got = append(got, result.Field.Path+": "+result.Message)
+			}
+			if !reflect.DeepEqual(got, tc.results) {
+				t.Errorf("expected results\n%q\ngot\n%q", tc.results, got)
+			}
+
+			var spec, want interface{}
+			if err := obj.GetMap("spec").As(&spec); err != nil {
+				t.Fatal(err)
+			}
+			if err := testApp(t, tc.want).GetMap("spec").As(&want); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec, want) {
+				t.Errorf("expected the spec\n%v\ngot\n%v", want, spec)
+			}
+			// the defaults are valid and integers are not written as floats
+			if results := v.Validate(obj); len(results) > 0 {
+				t.Errorf("expected the defaulted App to be valid, got %v\n%s", results, obj)
+			}
+		})
+	}
+}
+
+func TestDefaulting(t *testing.T) {
+	v := testValidator(t)
+	p := fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		if mode, _, _ := rctx.Input.Origin.NestedString("spec", "mode"); mode != "active" {
+			t.Errorf("expected the origin to be defaulted before the processor runs, got mode %q", mode)
+		}
+		rctx.Outputs = append(rctx.Outputs, testApp(t, `{image: nginx, mode: standby, replicas: 2, resources: {}}`))
+		return true, nil
+	})
+	rctx := &fn.ResourceContext{Input: &fn.ResourceContextInputs{
+		Origin: testApp(t, `{image: nginx, replicas: 2, resources: {cpu: "1"}}`),
+	}}
+	success, err := v.Defaulting(p).ProcessContext(context.Background(), rctx)
+	if err != nil || !success {
+		t.Fatalf("expected success, got %v, %v", success, err)
+	}
+	var got []string
+	for _, result := range rctx.Results {
+		got = append(got, result.Field.Path)
+	}
+	if want := []string{"spec.mode", "spec.resources.cpu"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expected the origin and output defaults as results, got %v", got)
+	}
+	if cpu, _, _ := rctx.Outputs[0].NestedString("spec", "resources", "cpu"); cpu != "100m" {
+		t.Errorf("expected the output to be defaulted, got cpu %q", cpu)
+	}
+}
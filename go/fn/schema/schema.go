@@ -0,0 +1,124 @@
+// Package schema validates KubeObjects against the OpenAPI v3 schemas of CRDs,
+// e.g. those generated into config/crd/bases, or of OpenAPI documents.
+package schema
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Schema is the subset of an OpenAPI v3 schema, as used by the structural
+// schemas of CRDs, that is validated.
+type Schema struct {
+	Ref         string `json:"$ref,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Format      string `json:"format,omitempty"`
+	Description string `json:"description,omitempty"`
+	Nullable    bool   `json:"nullable,omitempty"`
+
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	AdditionalProperties *SchemaOrBool      `json:"additionalProperties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	MinProperties        *int64             `json:"minProperties,omitempty"`
+	MaxProperties        *int64             `json:"maxProperties,omitempty"`
+
+	Items    *Schema `json:"items,omitempty"`
+	MinItems *int64  `json:"minItems,omitempty"`
+	MaxItems *int64  `json:"maxItems,omitempty"`
+
+	Enum      []interface{} `json:"enum,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	MinLength *int64        `json:"minLength,omitempty"`
+	MaxLength *int64        `json:"maxLength,omitempty"`
+
+	Minimum          *float64 `json:"minimum,omitempty"`
+	Maximum          *float64 `json:"maximum,omitempty"`
+	ExclusiveMinimum bool     `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool     `json:"exclusiveMaximum,omitempty"`
+
+	Default interface{} `json:"default,omitempty"`
+
+	AllOf []*Schema `json:"allOf,omitempty"`
+
+	XPreserveUnknownFields *bool `json:"x-kubernetes-preserve-unknown-fields,omitempty"`
+	XEmbeddedResource      bool  `json:"x-kubernetes-embedded-resource,omitempty"`
+	XIntOrString           bool  `json:"x-kubernetes-int-or-string,omitempty"`
+
+	// resolved is the schema Ref points to.
+	resolved *Schema
+}
+
+// SchemaOrBool is the value of additionalProperties: either a schema for the
+// additional properties or whether they are allowed at all.
+type SchemaOrBool struct {
+	Allows bool
+	Schema *Schema
+}
+
+func (s *SchemaOrBool) UnmarshalJSON(b []byte) error {
+	var allows bool
+	if err := json.Unmarshal(b, &allows); err == nil {
+		*s = SchemaOrBool{Allows: allows}
+		return nil
+	}
+	s.Allows = true
+	return json.Unmarshal(b, &s.Schema)
+}
+
+func (s SchemaOrBool) MarshalJSON() ([]byte, error) {
+	if s.Schema != nil {
+		return json.Marshal(s.Schema)
+	}
+	return json.Marshal(s.Allows)
+}
+
+// deref returns the schema s refers to.
+func (s *Schema) deref() *Schema {
+	for i := 0; s != nil && s.Ref != "" && i < 32; i++ {
+		if s.resolved == nil {
+			return s
+		}
+		s = s.resolved
+	}
+	return s
+}
+
+// preservesUnknownFields tells whether fields that are not in Properties are
+// kept rather than pruned.
+func (s *Schema) preservesUnknownFields() bool {
+	return s.XPreserveUnknownFields != nil && *s.XPreserveUnknownFields
+}
+
+// resolveRefs points the local references "#/..." of s and of its sub schemas
+// to the schemas in defs, keyed by the reference.
+func (s *Schema) resolveRefs(defs map[string]*Schema, seen map[*Schema]bool) {
+	if s == nil || seen[s] {
+		return
+	}
+	seen[s] = true
+	if s.Ref != "" {
+		s.resolved = defs[s.Ref]
+	}
+	for _, p := range s.Properties {
+		p.resolveRefs(defs, seen)
+	}
+	if s.AdditionalProperties != nil {
+		s.AdditionalProperties.Schema.resolveRefs(defs, seen)
+	}
+	s.Items.resolveRefs(defs, seen)
+	for _, sub := range s.AllOf {
+		sub.resolveRefs(defs, seen)
+	}
+}
+
+// fieldPath appends a field to a path in the form spec.list[0].field.
+func fieldPath(path, field string) string {
+	if strings.ContainsAny(field, ".[]") {
+		field = "[" + field + "]"
+		return path + field
+	}
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
@@ -0,0 +1,473 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+// Validator validates KubeObjects against the schemas of their kinds.
+// KubeObjects of kinds without a schema are not validated.
+type Validator struct {
+	schemas map[fn.GroupVersionKind]*Schema
+
+	mu       sync.Mutex
+	patterns map[string]*regexp.Regexp
+}
+
+// NewValidator returns a Validator without schemas.
+func NewValidator() *Validator {
+	return &Validator{
+		schemas:  map[fn.GroupVersionKind]*Schema{},
+		patterns: map[string]*regexp.Regexp{},
+	}
+}
+
+// Load returns a Validator with the schemas found in the given files and in the
+// yaml and json files of the given directories, see Validator.Load.
+func Load(paths ...string) (*Validator, error) {
+	v := NewValidator()
+	if err := v.Load(paths...); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+// Load adds the schemas of the CRDs and OpenAPI documents found in the given
+// files and in the yaml and json files of the given directories.
+func (v *Validator) Load(paths ...string) error {
+	for _, path := range paths {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+			switch filepath.Ext(file) {
+			case ".yaml", ".yml", ".json":
+			default:
+				if file != path {
+					return nil
+				}
+			}
+			b, err := ioutil.ReadFile(file)
+			if err != nil {
+				return err
+			}
+			if err := v.AddDocuments(b); err != nil {
+				return fmt.Errorf("%s: %w", file, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AddDocuments adds the schemas of the CRDs and OpenAPI documents in b, which
+// can hold several yaml documents. Other documents are ignored.
+func (v *Validator) AddDocuments(b []byte) error {
+	objs, err := fn.ParseKubeObjects(b)
+	if err != nil {
+		return err
+	}
+	for _, obj := range objs {
+		switch {
+		case obj.IsGVK("apiextensions.k8s.io", "v1", "CustomResourceDefinition"):
+			if err := v.AddCRD(obj); err != nil {
+				return err
+			}
+		case isOpenAPI(obj):
+			if err := v.AddOpenAPI(obj); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// crd is the part of a CustomResourceDefinition holding the schemas.
+type crd struct {
+	Spec struct {
+		Group string `json:"group"`
+		Names struct {
+			Kind string `json:"kind"`
+		} `json:"names"`
+		Versions []struct {
+			Name   string `json:"name"`
+			Schema *struct {
+				OpenAPIV3Schema *Schema `json:"openAPIV3Schema"`
+			} `json:"schema"`
+		} `json:"versions"`
+	} `json:"spec"`
+}
+
+// AddCRD adds the schemas of every version of an apiextensions.k8s.io/v1
+// CustomResourceDefinition.
+func (v *Validator) AddCRD(obj *fn.KubeObject) error {
+	c := &crd{}
+	if err := obj.As(c); err != nil {
+		return err
+	}
+	for _, version := range c.Spec.Versions {
+		if version.Schema == nil || version.Schema.OpenAPIV3Schema == nil {
+			continue
+		}
+		v.Add(fn.GroupVersionKind{Group: c.Spec.Group, Version: version.Name, Kind: c.Spec.Names.Kind},
+			version.Schema.OpenAPIV3Schema)
+	}
+	return nil
+}
+
+// openAPI is the part of an OpenAPI v2 or v3 document holding the schemas.
+type openAPI struct {
+	Definitions map[string]*openAPISchema `json:"definitions"`
+	Components  struct {
+		Schemas map[string]*openAPISchema `json:"schemas"`
+	} `json:"components"`
+}
+
+type openAPISchema struct {
+	Schema
+	GVKs []fn.GroupVersionKind `json:"x-kubernetes-group-version-kind,omitempty"`
+}
+
+func isOpenAPI(obj *fn.KubeObject) bool {
+	for _, field := range []string{"openapi", "swagger"} {
+		if _, found, _ := obj.NestedString(field); found {
+			return true
+		}
+	}
+	return false
+}
+
+// AddOpenAPI adds the schemas of an OpenAPI v2 or v3 document that declare the
+// kinds they describe with x-kubernetes-group-version-kind, e.g. the document
+// served by the API server on /openapi/v2.
+func (v *Validator) AddOpenAPI(obj *fn.KubeObject) error {
+	doc := &openAPI{}
+	if err := obj.As(doc); err != nil {
+		return err
+	}
+	defs := map[string]*Schema{}
+	var schemas []*openAPISchema
+	for name, s := range doc.Definitions {
+		defs["#/definitions/"+name] = &s.Schema
+		schemas = append(schemas, s)
+	}
+	for name, s := range doc.Components.Schemas {
+		defs["#/components/schemas/"+name] = &s.Schema
+		schemas = append(schemas, s)
+	}
+	seen := map[*Schema]bool{}
+	for _, s := range schemas {
+		s.resolveRefs(defs, seen)
+		for _, gvk := range s.GVKs {
+			v.Add(gvk, &s.Schema)
+		}
+	}
+	return nil
+}
+
+// Add adds the schema of gvk. The core group is the empty group.
+func (v *Validator) Add(gvk fn.GroupVersionKind, s *Schema) {
+	v.schemas[gvk] = s
+}
+
+// Schema returns the schema of the kind of obj.
+func (v *Validator) Schema(obj *fn.KubeObject) (*Schema, bool) {
+	s, ok := v.schemas[fn.GVKOf(obj)]
+	return s, ok
+}
+
+// Validate validates obj against the schema of its kind and returns an error
+// Result for every violation.
+func (v *Validator) Validate(obj *fn.KubeObject) fn.Results {
+	s, ok := v.Schema(obj)
+	if !ok {
+		return nil
+	}
+	var value interface{}
+	if err := obj.As(&value); err != nil {
+		return fn.Results{fn.ConfigObjectResult(err.Error(), obj, fn.Error)}
+	}
+	var results fn.Results
+	for _, err := range v.validate(s, value, "", true) {
+		results = append(results, fn.ConfigFieldResult(err.message, obj, fn.Field{Path: err.path, CurrentValue: err.value}, fn.Error))
+	}
+	return results
+}
+
+// ValidateContext validates the origin, the items and the outputs of rctx.
+func (v *Validator) ValidateContext(rctx *fn.ResourceContext) fn.Results {
+	return append(v.ValidateInput(rctx), v.ValidateOutputs(rctx)...)
+}
+
+// ValidateInput validates the origin and the items of rctx.
+func (v *Validator) ValidateInput(rctx *fn.ResourceContext) fn.Results {
+	if rctx.Input == nil {
+		return nil
+	}
+	var results fn.Results
+	if rctx.Input.Origin != nil {
+		results = append(results, v.Validate(rctx.Input.Origin)...)
+	}
+	for _, item := range rctx.Input.Items {
+		results = append(results, v.Validate(item)...)
+	}
+	return results
+}
+
+// ValidateOutputs validates the outputs of rctx.
+func (v *Validator) ValidateOutputs(rctx *fn.ResourceContext) fn.Results {
+	var results fn.Results
+	for _, output := range rctx.Outputs {
+		results = append(results, v.Validate(output)...)
+	}
+	return results
+}
+
+// Processor returns a processor that validates the input before it calls p and
+// the outputs after p returns. Violations are reported as Results and fail the
+// run.
+func (v *Validator) Processor(p fn.ContextProcessor) fn.ContextProcessor {
+	return fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		if results := v.ValidateInput(rctx); len(results) > 0 {
+			rctx.LogResult(results)
+			return false, results
+		}
+		success, err := p.ProcessContext(ctx, rctx)
+		if err != nil || !success {
+			return success, err
+		}
+		if results := v.ValidateOutputs(rctx); len(results) > 0 {
+			rctx.LogResult(results)
+			return false, results
+		}
+		return true, nil
+	})
+}
+
+// violation is a value that doesn't satisfy its schema.
+type violation struct {
+	path    string
+	message string
+	value   interface{}
+}
+
+func (v *Validator) validate(s *Schema, value interface{}, path string, root bool) []violation {
+	s = s.deref()
+	if s == nil {
+		return nil
+	}
+	var errs []violation
+	for _, sub := range s.AllOf {
+		errs = append(errs, v.validate(sub, value, path, root)...)
+	}
+	if value == nil {
+		if s.Nullable || s.Type == "" {
+			return errs
+		}
+		return append(errs, violation{path, fmt.Sprintf("must be of type %s, got null", s.Type), nil})
+	}
+	if msg := checkType(s, value); msg != "" {
+		return append(errs, violation{path, msg, value})
+	}
+	if len(s.Enum) > 0 && !inEnum(s.Enum, value) {
+		errs = append(errs, violation{path, fmt.Sprintf("must be one of %s", enumList(s.Enum)), value})
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		errs = append(errs, v.validateObject(s, value, path, root)...)
+	case []interface{}:
+		if s.MinItems != nil && int64(len(value)) < *s.MinItems {
+			errs = append(errs, violation{path, fmt.Sprintf("must have at least %d items", *s.MinItems), nil})
+		}
+		if s.MaxItems != nil && int64(len(value)) > *s.MaxItems {
+			errs = append(errs, violation{path, fmt.Sprintf("must have at most %d items", *s.MaxItems), nil})
+		}
+		if s.Items != nil {
+			for i, item := range value {
+				errs = append(errs, v.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), false)...)
+			}
+		}
+	case string:
+		n := int64(utf8.RuneCountInString(value))
+		if s.MinLength != nil && n < *s.MinLength {
+			errs = append(errs, violation{path, fmt.Sprintf("must be at least %d characters long", *s.MinLength), value})
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			errs = append(errs, violation{path, fmt.Sprintf("must be at most %d characters long", *s.MaxLength), value})
+		}
+		if s.Pattern != "" {
+			re, err := v.pattern(s.Pattern)
+			if err != nil {
+				errs = append(errs, violation{path, fmt.Sprintf("invalid pattern %q in schema: %v", s.Pattern, err), value})
+			} else if !re.MatchString(value) {
+				errs = append(errs, violation{path, fmt.Sprintf("must match pattern %q", s.Pattern), value})
+			}
+		}
+	case float64:
+		if s.Minimum != nil && (value < *s.Minimum || s.ExclusiveMinimum && value == *s.Minimum) {
+			errs = append(errs, violation{path, fmt.Sprintf("must be greater than %s%v", orEqual(!s.ExclusiveMinimum), *s.Minimum), value})
+		}
+		if s.Maximum != nil && (value > *s.Maximum || s.ExclusiveMaximum && value == *s.Maximum) {
+			errs = append(errs, violation{path, fmt.Sprintf("must be less than %s%v", orEqual(!s.ExclusiveMaximum), *s.Maximum), value})
+		}
+	}
+	return errs
+}
+
+func (v *Validator) validateObject(s *Schema, value map[string]interface{}, path string, root bool) []violation {
+	var errs []violation
+	n := int64(len(value))
+	if s.MinProperties != nil && n < *s.MinProperties {
+		errs = append(errs, violation{path, fmt.Sprintf("must have at least %d properties", *s.MinProperties), nil})
+	}
+	if s.MaxProperties != nil && n > *s.MaxProperties {
+		errs = append(errs, violation{path, fmt.Sprintf("must have at most %d properties", *s.MaxProperties), nil})
+	}
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			errs = append(errs, violation{fieldPath(path, name), "is required", nil})
+		}
+	}
+	// the type and object meta of resources are validated by the API server
+	// rather than by their schema.
+	resource := root || s.XEmbeddedResource
+
+	keys := make([]string, 0, len(value))
+	for k := range value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		p := fieldPath(path, k)
+		if prop, ok := s.Properties[k]; ok {
+			errs = append(errs, v.validate(prop, value[k], p, false)...)
+			continue
+		}
+		switch {
+		case resource && (k == "apiVersion" || k == "kind" || k == "metadata"):
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+			errs = append(errs, v.validate(s.AdditionalProperties.Schema, value[k], p, false)...)
+		case s.AdditionalProperties != nil && s.AdditionalProperties.Allows:
+		case s.preservesUnknownFields():
+		case len(s.Properties) == 0 && s.AdditionalProperties == nil:
+			// a schema without properties accepts any field, e.g. metadata
+		default:
+			errs = append(errs, violation{p, "unknown field", nil})
+		}
+	}
+	return errs
+}
+
+func (v *Validator) pattern(pattern string) (*regexp.Regexp, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if re, ok := v.patterns[pattern]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	v.patterns[pattern] = re
+	return re, nil
+}
+
+// checkType returns why value is not of the type of s, if it isn't.
+func checkType(s *Schema, value interface{}) string {
+	if s.XIntOrString {
+		switch value := value.(type) {
+		case string:
+			return ""
+		case float64:
+			if value == math.Trunc(value) {
+				return ""
+			}
+		}
+		return fmt.Sprintf("must be an integer or a string, got %s", typeOf(value))
+	}
+	var ok bool
+	switch s.Type {
+	case "":
+		return ""
+	case "object":
+		_, ok = value.(map[string]interface{})
+	case "array":
+		_, ok = value.([]interface{})
+	case "string":
+		_, ok = value.(string)
+	case "boolean":
+		_, ok = value.(bool)
+	case "number":
+		_, ok = value.(float64)
+	case "integer":
+		var f float64
+		f, ok = value.(float64)
+		ok = ok && f == math.Trunc(f)
+	}
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf("must be of type %s, got %s", s.Type, typeOf(value))
+}
+
+func typeOf(value interface{}) string {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func inEnum(enum []interface{}, value interface{}) bool {
+	for _, e := range enum {
+		if reflect.DeepEqual(e, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func enumList(enum []interface{}) string {
+	var values []string
+	for _, e := range enum {
+		values = append(values, fmt.Sprintf("%v", e))
+	}
+	return strings.Join(values, ", ")
+}
+
+func orEqual(inclusive bool) string {
+	if inclusive {
+		return "or equal to "
+	}
+	return ""
+}
@@ -0,0 +1,225 @@
+package schema
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+)
+
+const testCRD = `apiVersion: apiextensions.k8s.io/v1
+kind: CustomResourceDefinition
+metadata:
+  name: apps.app.yndd.io
+spec:
+  group: app.yndd.io
+  names:
+    kind: App
+  versions:
+  - name: v1alpha1
+    schema:
+      openAPIV3Schema:
+        type: object
+        properties:
+          spec:
+            type: object
+            required: [image]
+            properties:
+              image:
+                type: string
+                pattern: '^[a-z]+(:[a-z0-9.]+)?$'
+              replicas:
+                type: integer
+                minimum: 1
+                maximum: 10
+                default: 1
+              mode:
+                type: string
+                enum: [active, standby]
+                default: active
+              port:
+                x-kubernetes-int-or-string: true
+              labels:
+                type: object
+                additionalProperties:
+                  type: string
+              resources:
+                type: object
+                default: {}
+                properties:
+                  cpu:
+                    type: string
+                    default: 100m
+                  memory:
+                    type: string
+              ports:
+                type: array
+                maxItems: 2
+                items:
+                  type: object
+                  required: [port]
+                  properties:
+                    port:
+                      type: integer
+                    protocol:
+                      type: string
+                      default: TCP
+              tags:
+                type: array
+                items:
+                  type: string
+              config:
+                type: object
+                x-kubernetes-preserve-unknown-fields: true
+`
+
+// testValidator returns a Validator with the schema of testCRD.
+func testValidator(t *testing.T) *Validator {
+	t.Helper()
+	v := NewValidator()
+	if err := v.AddDocuments([]byte(testCRD)); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// testApp returns an App with spec.
+func testApp(t *testing.T, spec string) *fn.KubeObject {
+	t.Helper()
+	obj, err := fn.ParseKubeObject([]byte("apiVersion: app.yndd.io/v1alpha1\nkind: App\nmetadata:\n  name: app1\n  namespace: ns\nspec: " + spec + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return obj
+}
+
+func TestValidate(t *testing.T) {
+	tests := map[string]struct {
+		spec string
+		want []string
+	}{
+		"valid": {
+			spec: `{image: "nginx:1.25", replicas: 3, mode: standby, port: http, labels: {a: b}, ports: [{port: 80}], config: {any: [thing]}}`,
+		},
+		"int port": {
+			spec: `{image: nginx, port: 8080}`,
+		},
+		"required": {
+			spec: `{replicas: 3}`,
+			want: []string{"spec.image: is required"},
+		},
+		"types": {
+			spec: `{image: 1, replicas: 1.5, port: 1.5, tags: x}`,
+			want: []string{
+				"spec.image: must be of type string, got integer",
+				"spec.port: must be an integer or a string, got number",
+				"spec.replicas: must be of type integer, got number",
+				"spec.tags: must be of type array, got string",
+			},
+		},
+		"null": {
+			spec: `{image: null}`,
+			want: []string{"spec.image: must be of type string, got null"},
+		},
+		"constraints": {
+			spec: `{image: "NGINX", replicas: 11, mode: passive}`,
+			want: []string{
+				`spec.image: must match pattern "^[a-z]+(:[a-z0-9.]+)?$"`,
+				"spec.mode: must be one of active, standby",
+				"spec.replicas: must be less than or equal to 10",
+			},
+		},
+		"nested": {
+			spec: `{image: nginx, labels: {a: 1}, ports: [{protocol: UDP}, {port: x}, {port: 1}], tags: [a, 1]}`,
+			want: []string{
+				"spec.labels.a: must be of type string, got integer",
+				"spec.ports: must have at most 2 items",
+				"spec.ports[0].port: is required",
+				"spec.ports[1].port: must be of type integer, got string",
+				"spec.tags[1]: must be of type string, got integer",
+			},
+		},
+		"unknown fields": {
+			spec: `{image: nginx, imgae: nginx, "a.b": c, resources: {gpu: 1}}`,
+			want: []string{
+				"spec[a.b]: unknown field",
+				"spec.imgae: unknown field",
+				"spec.resources.gpu: unknown field",
+			},
+		},
+	}
+	v := testValidator(t)
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			var got []string
+			for _, result := range v.Validate(testApp(t, tc.spec)) {
+				if result.Severity != fn.Error {
+					t.Errorf("expected an error Result, got %s", result.Severity)
+				}
+				ref := result.ResourceRef
+				if ref == nil || ref.Kind != "App" || ref.Name != "app1" || ref.Namespace != "ns" {
+					t.Errorf("expected the Result to refer to the App, got %+v", ref)
+				}
+				if result.Field == nil {
+					t.Fatalf("expected the Result to have a field, got %v", result)
+				}
+				got = append(got, result.Field.Path+": "+result.Message)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected\n%q\ngot\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestValidateUnknownKind(t *testing.T) {
+	obj, err := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\ndata: 1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if results := testValidator(t).Validate(obj); len(results) != 0 {
+		t.Errorf("expected a kind without schema not to be validated, got %v", results)
+	}
+}
+
+func TestProcessor(t *testing.T) {
+	tests := map[string]struct {
+		origin, output string
+		called         bool
+		want           string
+	}{
+		"valid":          {origin: `{image: nginx}`, output: `{image: nginx}`, called: true},
+		"invalid origin": {origin: `{}`, output: `{image: nginx}`, want: "spec.image"},
+		"invalid output": {origin: `{image: nginx}`, output: `{image: nginx, replicas: 0}`, called: true, want: "spec.replicas"},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			called := false
+			p := fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+				called = true
+				rctx.Outputs = append(rctx.Outputs, testApp(t, tc.output))
+				return true, nil
+			})
+			rctx := &fn.ResourceContext{Input: &fn.ResourceContextInputs{Origin: testApp(t, tc.origin)}}
+			success, err := testValidator(t).Processor(p).ProcessContext(context.Background(), rctx)
+			if called != tc.called {
+				t.Errorf("expected the processor to be called: %v", tc.called)
+			}
+			if tc.want == "" {
+				if err != nil || !success || len(rctx.Results) != 0 {
+					t.Errorf("expected success, got %v, %v, %v", success, err, rctx.Results)
+				}
+				return
+			}
+			var results fn.Results
+			if success || !errors.As(err, &results) {
+				t.Fatalf("expected the Results as error, got %v, %v", success, err)
+			}
+			if len(rctx.Results) != 1 || rctx.Results[0].Field.Path != tc.want {
+				t.Errorf("expected a Result for %s, got %v", tc.want, rctx.Results)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultServeAddress is the address Serve listens on when none is given.
+	DefaultServeAddress = ":8080"
+
+	// ContentTypeYAML is the content type of the ResourceContext exchanged with
+	// the HTTP handler.
+	ContentTypeYAML = "application/yaml"
+
+	// HeaderFunctionError carries the function error of a run whose response
+	// body was still produced.
+	HeaderFunctionError = "X-Function-Error"
+
+	// DefaultMaxRequestSize bounds the ResourceContext the HTTP handler reads
+	// when no limit is configured.
+	DefaultMaxRequestSize = 64 << 20
+)
+
+// ServerOption configures Serve.
+type ServerOption func(*serverOptions)
+
+type serverOptions struct {
+	runOptions      []RunOption
+	handlers        map[string]http.Handler
+	shutdownTimeout time.Duration
+	maxRequestSize  int64
+}
+
+// WithServerRunOptions passes opts to every run executed by the server.
+func WithServerRunOptions(opts ...RunOption) ServerOption {
+	return func(o *serverOptions) {
+		o.runOptions = append(o.runOptions, opts...)
+	}
+}
+
+// WithServerHandler mounts an additional handler, e.g. a metrics endpoint, at
+// pattern.
+func WithServerHandler(pattern string, h http.Handler) ServerOption {
+	return func(o *serverOptions) {
+		o.handlers[pattern] = h
+	}
+}
+
+// WithShutdownTimeout bounds the time in-flight runs get to finish once the
+// server context is done.
+func WithShutdownTimeout(d time.Duration) ServerOption {
+	return func(o *serverOptions) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithMaxRequestSize bounds the ResourceContext the server reads from a
+// request; larger requests are rejected with status 413.
+func WithMaxRequestSize(n int64) ServerOption {
+	return func(o *serverOptions) {
+		o.maxRequestSize = n
+	}
+}
+
+// NewHTTPHandler returns a handler that runs p for every ResourceContext that
+// is POSTed to it. The response body is the resulting ResourceContext. A
+// function error that still produced a response is reported with status 200 and
+// the HeaderFunctionError header, since the Results carry the details. Requests
+// larger than DefaultMaxRequestSize are rejected with status 413.
+func NewHTTPHandler(p ContextProcessor, opts ...RunOption) http.Handler {
+	return newHTTPHandler(p, DefaultMaxRequestSize, opts...)
+}
+
+func newHTTPHandler(p ContextProcessor, maxRequestSize int64, opts ...RunOption) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, fmt.Sprintf("method %s not allowed", r.Method), http.StatusMethodNotAllowed)
+			return
+		}
+		in, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, fmt.Sprintf("request body larger than %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, fmt.Sprintf("unable to read request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		out, err := RunContext(r.Context(), p, in, opts...)
+		if out == nil {
+			status := http.StatusInternalServerError
+			if err == nil {
+				err = fmt.Errorf("function returned no output")
+			} else if _, parseErr := ParseResourceContext(in); parseErr != nil {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeYAML)
+		if err != nil {
+			w.Header().Set(HeaderFunctionError, err.Error())
+		}
+		if _, err := w.Write(out); err != nil {
+			Logf("failed to write response: %v\n", err)
+		}
+	})
+}
+
+// Serve runs p as a long-running function server on addr until ctx is done. The
+// processor is served at "/" and additional handlers can be mounted with
+// WithServerHandler. The server speaks HTTP only; there is no gRPC endpoint.
+func Serve(ctx context.Context, addr string, p ContextProcessor, opts ...ServerOption) error {
+	o := &serverOptions{
+		handlers:        map[string]http.Handler{},
+		shutdownTimeout: 30 * time.Second,
+		maxRequestSize:  DefaultMaxRequestSize,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if addr == "" {
+		addr = DefaultServeAddress
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", newHTTPHandler(p, o.maxRequestSize, o.runOptions...))
+	for pattern, h := range o.handlers {
+		mux.Handle(pattern, h)
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), o.shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			return err
+		}
+		if err := <-errCh; err != nil && !errors.Is(err, http.ErrServerClosed) {
+			return err
+		}
+		return nil
+	}
+}
@@ -0,0 +1,108 @@
+package fn
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHTTPHandler(t *testing.T) {
+	tests := map[string]struct {
+		method        string
+		body          string
+		fnErr         error
+		maxSize       int64
+		wantStatus    int
+		wantFnError   string
+		wantOutputs   int
+		wantBodyMatch string
+	}{
+		"success": {
+			method:      http.MethodPost,
+			body:        testInput,
+			wantStatus:  http.StatusOK,
+			wantOutputs: 1,
+		},
+		"function error": {
+			method:      http.MethodPost,
+			body:        testInput,
+			fnErr:       errors.New("transform failed"),
+			wantStatus:  http.StatusOK,
+			wantFnError: "transform failed",
+			wantOutputs: 1,
+		},
+		"invalid input": {
+			method:        http.MethodPost,
+			body:          "kind: Unknown\n",
+			wantStatus:    http.StatusBadRequest,
+			wantBodyMatch: "unexpected kind",
+		},
+		"request too large": {
+			method:        http.MethodPost,
+			body:          testInput,
+			maxSize:       16,
+			wantStatus:    http.StatusRequestEntityTooLarge,
+			wantBodyMatch: "larger than 16 bytes",
+		},
+		"wrong method": {
+			method:     http.MethodGet,
+			wantStatus: http.StatusMethodNotAllowed,
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			output := testOutput(t)
+			p := ContextProcessorFunc(func(ctx context.Context, rctx *ResourceContext) (bool, error) {
+				rctx.Outputs = append(rctx.Outputs, output)
+				return tc.fnErr == nil, tc.fnErr
+			})
+			maxSize := tc.maxSize
+			if maxSize == 0 {
+				maxSize = DefaultMaxRequestSize
+			}
+			srv := httptest.NewServer(newHTTPHandler(p, maxSize))
+			defer srv.Close()
+
+			req, err := http.NewRequest(tc.method, srv.URL, strings.NewReader(tc.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := srv.Client().Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			body := new(strings.Builder)
+			if _, err := io.Copy(body, resp.Body); err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.StatusCode != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, resp.StatusCode, body)
+			}
+			if got := resp.Header.Get(HeaderFunctionError); got != tc.wantFnError {
+				t.Errorf("expected the %s header %q, got %q", HeaderFunctionError, tc.wantFnError, got)
+			}
+			if tc.wantBodyMatch != "" && !strings.Contains(body.String(), tc.wantBodyMatch) {
+				t.Errorf("expected the body to contain %q, got %q", tc.wantBodyMatch, body)
+			}
+			if tc.wantStatus != http.StatusOK {
+				return
+			}
+			if ct := resp.Header.Get("Content-Type"); ct != ContentTypeYAML {
+				t.Errorf("expected content type %s, got %s", ContentTypeYAML, ct)
+			}
+			rctx, err := ParseResourceContext([]byte(body.String()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rctx.Outputs) != tc.wantOutputs {
+				t.Errorf("expected %d outputs, got %d", tc.wantOutputs, len(rctx.Outputs))
+			}
+		})
+	}
+}
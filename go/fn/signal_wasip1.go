@@ -0,0 +1,11 @@
+//go:build wasip1
+
+package fn
+
+import "context"
+
+// signalContext returns a cancelable context. A WASI module receives no
+// signals; the host bounds its run instead.
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return context.WithCancel(parent)
+}
@@ -0,0 +1,193 @@
+package fn
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+)
+
+const (
+	// FunctionSpecKind is the kind of a FunctionSpec printed by ToYAML.
+	FunctionSpecKind = "FunctionSpec"
+
+	// DescribeFlag makes AsMain print the FunctionSpec instead of running the
+	// function.
+	DescribeFlag = "--describe"
+
+	// DescribePath is the path Serve exposes the FunctionSpec on.
+	DescribePath = "/describe"
+)
+
+// FunctionSpec describes what a function accepts and produces, so that an
+// orchestrator can discover and validate functions. Empty lists are not
+// enforced.
+type FunctionSpec struct {
+	// Name of the function.
+	Name string `yaml:"name,omitempty" json:"name,omitempty"`
+	// Description is a human readable description of the function.
+	Description string `yaml:"description,omitempty" json:"description,omitempty"`
+	// Origins are the origin kinds the function accepts.
+	Origins GroupVersionKinds `yaml:"origins,omitempty" json:"origins,omitempty"`
+	// Targets are the target kinds the function needs. If set, a target of one
+	// of these kinds is required.
+	Targets GroupVersionKinds `yaml:"targets,omitempty" json:"targets,omitempty"`
+	// Items are the kinds of the additional input items the function accepts.
+	Items GroupVersionKinds `yaml:"items,omitempty" json:"items,omitempty"`
+	// Outputs are the kinds the function may render. Outputs of other kinds are
+	// dropped and reported as error Results.
+	Outputs GroupVersionKinds `yaml:"outputs,omitempty" json:"outputs,omitempty"`
+}
+
+// WithFunctionSpec enforces spec on the run: the inputs are checked before the
+// processor is called and the outputs after it returns.
+func WithFunctionSpec(spec *FunctionSpec) RunOption {
+	return func(o *runOptions) {
+		o.spec = spec
+	}
+}
+
+// WithServerFunctionSpec enforces spec on every run of the server and exposes
+// it on DescribePath.
+func WithServerFunctionSpec(spec *FunctionSpec) ServerOption {
+	return func(o *serverOptions) {
+		o.runOptions = append(o.runOptions, WithFunctionSpec(spec))
+		o.handlers[DescribePath] = NewDescribeHandler(spec)
+	}
+}
+
+// ParseFunctionSpec parses a FunctionSpec as printed by ToYAML.
+func ParseFunctionSpec(in []byte) (*FunctionSpec, error) {
+	obj, err := ParseKubeObject(in)
+	if err != nil {
+		return nil, err
+	}
+	if obj.GetKind() != FunctionSpecKind {
+		return nil, fmt.Errorf("input was of unexpected kind %q; expected %s", obj.GetKind(), FunctionSpecKind)
+	}
+	spec := &FunctionSpec{}
+	if err := obj.As(spec); err != nil {
+		return nil, err
+	}
+	return spec, nil
+}
+
+// ToYAML converts the FunctionSpec to yaml.
+func (s *FunctionSpec) ToYAML() ([]byte, error) {
+	obj, err := NewFromTypedObject(struct {
+		APIVersion string `json:"apiVersion"`
+		Kind       string `json:"kind"`
+		*FunctionSpec
+	}{ResourceContextAPIVersion, FunctionSpecKind, s})
+	if err != nil {
+		return nil, err
+	}
+	return []byte(obj.String()), nil
+}
+
+// NewDescribeHandler returns a handler that serves spec in yaml.
+func NewDescribeHandler(spec *FunctionSpec) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		out, err := spec.ToYAML()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentTypeYAML)
+		if _, err := w.Write(out); err != nil {
+			Logf("failed to write response: %v\n", err)
+		}
+	})
+}
+
+// checkInputs returns an error Result for every input the spec doesn't accept.
+// Each input is checked on its own, so a missing origin doesn't hide the other
+// violations.
+func (s *FunctionSpec) checkInputs(rctx *ResourceContext) Results {
+	input := &ResourceContextInputs{}
+	if rctx.Input != nil {
+		input = rctx.Input
+	}
+	var results Results
+	if result := s.checkInput(input.Origin, "origin", s.Origins); result != nil {
+		results = append(results, result)
+	}
+	if result := s.checkInput(input.Target, "target", s.Targets); result != nil {
+		results = append(results, result)
+	}
+	if len(s.Items) > 0 {
+		for _, item := range input.Items {
+			if !s.Items.Matches(item) {
+				results = append(results, s.violation(item, "item", s.Items))
+			}
+		}
+	}
+	return results
+}
+
+// checkInput returns an error Result if declared kinds are set and obj, the
+// input of the role, is missing or of another kind.
+func (s *FunctionSpec) checkInput(obj *KubeObject, role string, declared GroupVersionKinds) *Result {
+	switch {
+	case len(declared) == 0:
+		return nil
+	case obj == nil:
+		return GeneralResult(fmt.Sprintf("function %s requires %s of kind %s",
+			s.Name, article(role), kindList(declared)), Error)
+	case !declared.Matches(obj):
+		return s.violation(obj, role, declared)
+	}
+	return nil
+}
+
+// article prefixes role with its indefinite article.
+func article(role string) string {
+	if strings.ContainsRune("aeiou", rune(role[0])) {
+		return "an " + role
+	}
+	return "a " + role
+}
+
+// enforceOutputs drops the outputs the spec doesn't declare and returns an
+// error Result for each of them.
+func (s *FunctionSpec) enforceOutputs(rctx *ResourceContext) Results {
+	if len(s.Outputs) == 0 {
+		return nil
+	}
+	var results Results
+	var outputs KubeObjects
+	for _, output := range rctx.Outputs {
+		if s.Outputs.Matches(output) {
+			outputs = append(outputs, output)
+			continue
+		}
+		results = append(results, s.violation(output, "output", s.Outputs))
+	}
+	rctx.Outputs = outputs
+	return results
+}
+
+func (s *FunctionSpec) violation(obj *KubeObject, role string, declared []GroupVersionKind) *Result {
+	result := GeneralResult(fmt.Sprintf("function %s does not declare %s kind %s; declared: %s",
+		s.Name, role, GVKOf(obj), kindList(declared)), Error)
+	result.ResourceRef = obj.resourceIdentifier()
+	return result
+}
+
+func kindList(gvks []GroupVersionKind) string {
+	var kinds []string
+	for _, gvk := range gvks {
+		kinds = append(kinds, gvk.String())
+	}
+	return strings.Join(kinds, ", ")
+}
+
+// describeRequested tells whether the binary was started with DescribeFlag.
+func describeRequested() bool {
+	for _, arg := range os.Args[1:] {
+		if arg == DescribeFlag || arg == "-describe" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package fn
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testSpec = &FunctionSpec{
+	Name:        "app-fn",
+	Description: "renders the config of an App",
+	Origins:     GroupVersionKinds{{Group: "app.yndd.io", Version: "v1alpha1", Kind: "App"}},
+	Targets:     GroupVersionKinds{{Group: "target.yndd.io", Version: "v1", Kind: "Target"}},
+	Items:       GroupVersionKinds{{Version: "v1", Kind: "Node"}},
+	Outputs:     GroupVersionKinds{{Version: "v1", Kind: "ConfigMap"}},
+}
+
+func TestFunctionSpecCheckInputs(t *testing.T) {
+	app := func(t *testing.T) *KubeObject { return testObject(t, "app.yndd.io/v1alpha1", "App") }
+	target := func(t *testing.T) *KubeObject { return testObject(t, "target.yndd.io/v1", "Target") }
+	node := func(t *testing.T) *KubeObject { return testObject(t, "v1", "Node") }
+
+	tests := map[string]struct {
+		input func(t *testing.T) *ResourceContextInputs
+		want  []string
+	}{
+		"accepted": {
+			input: func(t *testing.T) *ResourceContextInputs {
+				return &ResourceContextInputs{Origin: app(t), Target: target(t), Items: KubeObjects{node(t)}}
+			},
+		},
+		"missing target": {
+			input: func(t *testing.T) *ResourceContextInputs {
+				return &ResourceContextInputs{Origin: app(t)}
+			},
+			want: []string{"function app-fn requires a target of kind target.yndd.io/v1/Target"},
+		},
+		"missing origin and target": {
+			input: func(t *testing.T) *ResourceContextInputs {
+				return &ResourceContextInputs{Items: KubeObjects{node(t)}}
+			},
+			want: []string{
+				"function app-fn requires an origin of kind app.yndd.io/v1alpha1/App",
+				"function app-fn requires a target of kind target.yndd.io/v1/Target",
+			},
+		},
+		"no input": {
+			input: func(t *testing.T) *ResourceContextInputs { return nil },
+			want: []string{
+				"function app-fn requires an origin of kind app.yndd.io/v1alpha1/App",
+				"function app-fn requires a target of kind target.yndd.io/v1/Target",
+			},
+		},
+		"undeclared kinds without origin": {
+			input: func(t *testing.T) *ResourceContextInputs {
+				return &ResourceContextInputs{Target: node(t), Items: KubeObjects{node(t), target(t)}}
+			},
+			want: []string{
+				"function app-fn requires an origin of kind app.yndd.io/v1alpha1/App",
+				"function app-fn does not declare target kind v1/Node; declared: target.yndd.io/v1/Target",
+				"function app-fn does not declare item kind target.yndd.io/v1/Target; declared: v1/Node",
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			results := testSpec.checkInputs(&ResourceContext{Input: tc.input(t)})
+			var got []string
+			for _, result := range results {
+				if result.Severity != Error {
+					t.Errorf("expected an error Result, got %s", result.Severity)
+				}
+				got = append(got, result.Message)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("expected results\n%q\ngot\n%q", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestFunctionSpecDescribe(t *testing.T) {
+	var out bytes.Buffer
+	if err := describe(&out, testSpec); err != nil {
+		t.Fatal(err)
+	}
+	want := `name: app-fn
+apiVersion: app.yndd.io/v1
+kind: FunctionSpec
+description: renders the config of an App
+items:
+- kind: Node
+  version: v1
+origins:
+- kind: App
+  group: app.yndd.io
+  version: v1alpha1
+outputs:
+- kind: ConfigMap
+  version: v1
+targets:
+- kind: Target
+  group: target.yndd.io
+  version: v1
+`
+	if out.String() != want {
+		t.Errorf("unexpected description\n%s", out.String())
+	}
+	spec, err := ParseFunctionSpec(out.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(spec, testSpec) {
+		t.Errorf("expected the described spec to parse back, got %+v", spec)
+	}
+
+	if err := describe(&out, nil); err == nil || !strings.Contains(err.Error(), "no FunctionSpec") {
+		t.Errorf("expected an error without FunctionSpec, got %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	NewDescribeHandler(testSpec).ServeHTTP(w, httptest.NewRequest("GET", DescribePath, nil))
+	if w.Body.String() != want || w.Header().Get("Content-Type") != ContentTypeYAML {
+		t.Errorf("expected the handler to serve the description, got %q", w.Body.String())
+	}
+}
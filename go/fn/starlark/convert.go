@@ -0,0 +1,206 @@
+package starlark
+
+import (
+	"fmt"
+	"math/big"
+	"strconv"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+	"go.starlark.net/starlark"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+// toMapVariant returns the MapVariant of a copy of obj, so the yaml nodes keep
+// the comments of obj.
+func toMapVariant(obj *fn.KubeObject) (*internal.MapVariant, error) {
+	doc, err := internal.ParseDoc([]byte(obj.String()))
+	if err != nil {
+		return nil, err
+	}
+	objects, err := doc.Elements()
+	if err != nil {
+		return nil, err
+	}
+	if len(objects) != 1 {
+		return nil, fmt.Errorf("expected exactly one object, got %d", len(objects))
+	}
+	return objects[0], nil
+}
+
+// fromMapVariant returns the KubeObject of mv.
+func fromMapVariant(mv *internal.MapVariant) (*fn.KubeObject, error) {
+	b, err := internal.NewDoc(mv.Node()).ToYAML()
+	if err != nil {
+		return nil, err
+	}
+	return fn.ParseKubeObject(b)
+}
+
+// toStarlark converts a yaml node to a Starlark value: mappings become dicts,
+// sequences become lists and scalars become the value of their tag.
+func toStarlark(node *yaml.Node) (starlark.Value, error) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) == 0 {
+			return starlark.None, nil
+		}
+		return toStarlark(node.Content[0])
+	case yaml.AliasNode:
+		return toStarlark(node.Alias)
+	case yaml.MappingNode:
+		dict := starlark.NewDict(len(node.Content) / 2)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v, err := toStarlark(node.Content[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if err := dict.SetKey(starlark.String(node.Content[i].Value), v); err != nil {
+				return nil, err
+			}
+		}
+		return dict, nil
+	case yaml.SequenceNode:
+		elems := make([]starlark.Value, 0, len(node.Content))
+		for _, n := range node.Content {
+			v, err := toStarlark(n)
+			if err != nil {
+				return nil, err
+			}
+			elems = append(elems, v)
+		}
+		return starlark.NewList(elems), nil
+	case yaml.ScalarNode:
+		return scalarToStarlark(node)
+	}
+	return nil, fmt.Errorf("unhandled node kind %v", node.Kind)
+}
+
+func scalarToStarlark(node *yaml.Node) (starlark.Value, error) {
+	switch node.ShortTag() {
+	case yaml.NodeTagNull:
+		return starlark.None, nil
+	case yaml.NodeTagBool:
+		var b bool
+		if err := node.Decode(&b); err != nil {
+			return nil, err
+		}
+		return starlark.Bool(b), nil
+	case yaml.NodeTagInt:
+		var i int64
+		if err := node.Decode(&i); err == nil {
+			return starlark.MakeInt64(i), nil
+		}
+		bi, ok := new(big.Int).SetString(node.Value, 0)
+		if !ok {
+			return nil, fmt.Errorf("invalid integer %q", node.Value)
+		}
+		return starlark.MakeBigInt(bi), nil
+	case yaml.NodeTagFloat:
+		var f float64
+		if err := node.Decode(&f); err != nil {
+			return nil, err
+		}
+		return starlark.Float(f), nil
+	}
+	return starlark.String(node.Value), nil
+}
+
+// fromStarlark converts a Starlark value back to a yaml node. The nodes of orig,
+// the node v was converted from, are reused where v didn't change, so their
+// comments and styles are kept.
+func fromStarlark(v starlark.Value, orig *yaml.Node) (*yaml.Node, error) {
+	if orig != nil && orig.Kind == yaml.DocumentNode && len(orig.Content) > 0 {
+		orig = orig.Content[0]
+	}
+	switch v := v.(type) {
+	case *starlark.Dict:
+		node := newNode(yaml.MappingNode, yaml.NodeTagMap, "", orig)
+		for _, item := range v.Items() {
+			key, ok := starlark.AsString(item[0])
+			if !ok {
+				return nil, fmt.Errorf("dict keys must be strings, got %s", item[0].Type())
+			}
+			origKey, origValue := lookup(orig, key)
+			keyNode := origKey
+			if keyNode == nil {
+				keyNode = &yaml.Node{Kind: yaml.ScalarNode, Tag: yaml.NodeTagString, Value: key}
+			}
+			valueNode, err := fromStarlark(item[1], origValue)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			node.Content = append(node.Content, keyNode, valueNode)
+		}
+		return node, nil
+	case *starlark.List:
+		return sequenceFromStarlark(v, orig)
+	case starlark.Tuple:
+		return sequenceFromStarlark(v, orig)
+	}
+
+	if orig != nil && orig.Kind == yaml.ScalarNode {
+		if origValue, err := scalarToStarlark(orig); err == nil {
+			if eq, err := starlark.Equal(origValue, v); err == nil && eq && origValue.Type() == v.Type() {
+				return orig, nil
+			}
+		}
+	}
+	switch v := v.(type) {
+	case starlark.NoneType:
+		return newNode(yaml.ScalarNode, yaml.NodeTagNull, "null", orig), nil
+	case starlark.Bool:
+		return newNode(yaml.ScalarNode, yaml.NodeTagBool, strconv.FormatBool(bool(v)), orig), nil
+	case starlark.Int:
+		return newNode(yaml.ScalarNode, yaml.NodeTagInt, v.String(), orig), nil
+	case starlark.Float:
+		return newNode(yaml.ScalarNode, yaml.NodeTagFloat, strconv.FormatFloat(float64(v), 'g', -1, 64), orig), nil
+	case starlark.String:
+		return newNode(yaml.ScalarNode, yaml.NodeTagString, string(v), orig), nil
+	}
+	return nil, fmt.Errorf("unsupported type %s", v.Type())
+}
+
+func sequenceFromStarlark(v starlark.Indexable, orig *yaml.Node) (*yaml.Node, error) {
+	node := newNode(yaml.SequenceNode, yaml.NodeTagSeq, "", orig)
+	for i := 0; i < v.Len(); i++ {
+		var origElem *yaml.Node
+		if orig != nil && orig.Kind == yaml.SequenceNode && i < len(orig.Content) {
+			origElem = orig.Content[i]
+		}
+		elem, err := fromStarlark(v.Index(i), origElem)
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		node.Content = append(node.Content, elem)
+	}
+	return node, nil
+}
+
+// newNode returns a node that keeps the comments of orig, and its style if it
+// is of the same kind.
+func newNode(kind yaml.Kind, tag, value string, orig *yaml.Node) *yaml.Node {
+	node := &yaml.Node{Kind: kind, Tag: tag, Value: value}
+	if orig != nil {
+		node.HeadComment = orig.HeadComment
+		node.LineComment = orig.LineComment
+		node.FootComment = orig.FootComment
+		if orig.Kind == kind && kind != yaml.ScalarNode {
+			node.Style = orig.Style
+		}
+	}
+	return node
+}
+
+// lookup returns the key and value nodes of key in the mapping node m.
+func lookup(m *yaml.Node, key string) (*yaml.Node, *yaml.Node) {
+	if m == nil || m.Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i], m.Content[i+1]
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,366 @@
+// Package starlark provides a processor that runs a Starlark script against a
+// ResourceContext, for transforms written by people who don't write Go.
+//
+// The script sees the ResourceContext as plain, mutable Starlark values:
+//
+//	origin           the origin, a dict, or None
+//	target           the target, a dict, or None
+//	items            the items, a list of dicts
+//	outputs          the outputs, a list of dicts
+//	function_config  the functionConfig, a dict, or None
+//
+// and the builtins
+//
+//	add_output(obj)                                        appends obj to outputs
+//	result(message, severity="info", resource=None, field=None)  reports a Result
+//
+// e.g.
+//
+//	for item in items:
+//	    if item["kind"] == "Interface":
+//	        item["spec"]["mtu"] = origin["spec"]["mtu"]
+//	add_output({"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": origin["metadata"]["name"]}})
+//
+// The changes the script makes are written back into the yaml the objects were
+// read from, so the comments and styles of the fields it doesn't change are
+// kept.
+//
+// Scripts may use if, for and while statements at the top level, reassign
+// globals, recurse and use sets. These are enabled for the scripts of this
+// package only, the go.starlark.net/resolve flags are left untouched.
+package starlark
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+	"go.starlark.net/starlark"
+	"go.starlark.net/syntax"
+	"sigs.k8s.io/kustomize/kyaml/yaml"
+)
+
+const (
+	// SourceField is the field of the functionConfig, or the key of the data of
+	// a ConfigMap functionConfig, holding the script.
+	SourceField = "source"
+	// DefaultName is the file name of the script in errors.
+	DefaultName = "script.star"
+	// DefaultMaxSteps bounds the execution steps of a script.
+	DefaultMaxSteps = 10000000
+)
+
+// fileOptions is the dialect of the scripts, see the package doc. Scripts are
+// top-level statements, e.g. a for loop over the items, and may loop or recurse
+// since their execution steps are bounded.
+var fileOptions = &syntax.FileOptions{
+	Set:             true,
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+	Recursion:       true,
+}
+
+// Processor runs a Starlark script against the ResourceContext.
+type Processor struct {
+	// Source is the script. If it is empty the script is read from the
+	// functionConfig, see SourceField.
+	Source string
+	// Name is the file name of the script in errors. It defaults to DefaultName.
+	Name string
+	// MaxSteps bounds the execution steps of the script. It defaults to
+	// DefaultMaxSteps.
+	MaxSteps uint64
+}
+
+// New returns a Processor for the script source.
+func New(source string) *Processor {
+	return &Processor{Source: source}
+}
+
+// Process runs the script, see ProcessContext.
+func (p *Processor) Process(rctx *fn.ResourceContext) (bool, error) {
+	return p.ProcessContext(context.Background(), rctx)
+}
+
+// ProcessContext runs the script and writes the objects it changed back into
+// rctx. The script is cancelled when ctx is done or it exceeds its execution
+// steps. Script errors are reported as Results.
+func (p *Processor) ProcessContext(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+	source, err := p.source(rctx)
+	if err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+	s, err := newScript(rctx)
+	if err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+
+	thread := &starlark.Thread{
+		Name: p.name(),
+		Print: func(_ *starlark.Thread, msg string) {
+			fmt.Fprintln(os.Stderr, msg)
+		},
+	}
+	thread.SetMaxExecutionSteps(p.maxSteps())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			thread.Cancel(ctx.Err().Error())
+		case <-done:
+		}
+	}()
+
+	if _, err := starlark.ExecFileOptions(fileOptions, thread, p.name(), source, s.predeclared()); err != nil {
+		result := scriptResult(err)
+		rctx.Results = append(rctx.Results, s.results...)
+		rctx.LogResult(result)
+		return false, result
+	}
+	rctx.Results = append(rctx.Results, s.results...)
+	if err := s.writeBack(rctx); err != nil {
+		rctx.LogResult(err)
+		return false, err
+	}
+	for _, result := range s.results {
+		if result.Severity == fn.Error {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+func (p *Processor) source(rctx *fn.ResourceContext) (string, error) {
+	if p.Source != "" {
+		return p.Source, nil
+	}
+	if rctx.Input == nil || rctx.Input.FunctionConfig == nil {
+		return "", fmt.Errorf("no starlark source: the processor has no source and there is no functionConfig")
+	}
+	fnConfig := rctx.Input.FunctionConfig
+	var source string
+	if fnConfig.IsGVK("", "v1", "ConfigMap") {
+		data, err := rctx.GetFunctionConfigData()
+		if err != nil {
+			return "", err
+		}
+		source = data[SourceField]
+	} else {
+		var err error
+		if source, _, err = fnConfig.NestedString(SourceField); err != nil {
+			return "", fmt.Errorf("unable to get the starlark source of the functionConfig: %w", err)
+		}
+	}
+	if source == "" {
+		return "", fmt.Errorf("no starlark source: the functionConfig has no %s", SourceField)
+	}
+	return source, nil
+}
+
+func (p *Processor) name() string {
+	if p.Name == "" {
+		return DefaultName
+	}
+	return p.Name
+}
+
+func (p *Processor) maxSteps() uint64 {
+	if p.MaxSteps == 0 {
+		return DefaultMaxSteps
+	}
+	return p.MaxSteps
+}
+
+// script holds the Starlark values of a ResourceContext and the yaml nodes they
+// were converted from.
+type script struct {
+	origin         starlark.Value
+	target         starlark.Value
+	items          *starlark.List
+	outputs        *starlark.List
+	functionConfig starlark.Value
+	// nodes are the yaml nodes of the dicts converted from objects.
+	nodes   map[*starlark.Dict]*yaml.Node
+	results fn.Results
+}
+
+func newScript(rctx *fn.ResourceContext) (*script, error) {
+	s := &script{
+		origin:         starlark.None,
+		target:         starlark.None,
+		functionConfig: starlark.None,
+		nodes:          map[*starlark.Dict]*yaml.Node{},
+	}
+	var err error
+	var items fn.KubeObjects
+	if rctx.Input != nil {
+		if s.origin, err = s.toValue(rctx.Input.Origin); err != nil {
+			return nil, fmt.Errorf("unable to convert the origin: %w", err)
+		}
+		if s.target, err = s.toValue(rctx.Input.Target); err != nil {
+			return nil, fmt.Errorf("unable to convert the target: %w", err)
+		}
+		if s.functionConfig, err = s.toValue(rctx.Input.FunctionConfig); err != nil {
+			return nil, fmt.Errorf("unable to convert the functionConfig: %w", err)
+		}
+		items = rctx.Input.Items
+	}
+	if s.items, err = s.toList(items); err != nil {
+		return nil, fmt.Errorf("unable to convert the items: %w", err)
+	}
+	if s.outputs, err = s.toList(rctx.Outputs); err != nil {
+		return nil, fmt.Errorf("unable to convert the outputs: %w", err)
+	}
+	return s, nil
+}
+
+func (s *script) toValue(obj *fn.KubeObject) (starlark.Value, error) {
+	if obj == nil {
+		return starlark.None, nil
+	}
+	mv, err := toMapVariant(obj)
+	if err != nil {
+		return nil, err
+	}
+	v, err := toStarlark(mv.Node())
+	if err != nil {
+		return nil, err
+	}
+	if dict, ok := v.(*starlark.Dict); ok {
+		s.nodes[dict] = mv.Node()
+	}
+	return v, nil
+}
+
+func (s *script) toList(objs fn.KubeObjects) (*starlark.List, error) {
+	elems := make([]starlark.Value, 0, len(objs))
+	for _, obj := range objs {
+		v, err := s.toValue(obj)
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, v)
+	}
+	return starlark.NewList(elems), nil
+}
+
+func (s *script) predeclared() starlark.StringDict {
+	return starlark.StringDict{
+		"origin":          s.origin,
+		"target":          s.target,
+		"items":           s.items,
+		"outputs":         s.outputs,
+		"function_config": s.functionConfig,
+		"add_output":      starlark.NewBuiltin("add_output", s.addOutput),
+		"result":          starlark.NewBuiltin("result", s.result),
+	}
+}
+
+func (s *script) addOutput(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var obj *starlark.Dict
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &obj); err != nil {
+		return nil, err
+	}
+	if err := s.outputs.Append(obj); err != nil {
+		return nil, err
+	}
+	return starlark.None, nil
+}
+
+func (s *script) result(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var message, severity, field string
+	var resource starlark.Value = starlark.None
+	severity = string(fn.Info)
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs,
+		"message", &message, "severity?", &severity, "resource?", &resource, "field?", &field); err != nil {
+		return nil, err
+	}
+	switch fn.Severity(severity) {
+	case fn.Error, fn.Warning, fn.Info:
+	default:
+		return nil, fmt.Errorf("%s: invalid severity %q", b.Name(), severity)
+	}
+	result := fn.GeneralResult(message, fn.Severity(severity))
+	if resource != starlark.None {
+		obj, err := s.toObject(resource)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid resource: %w", b.Name(), err)
+		}
+		result = fn.ConfigObjectResult(message, obj, fn.Severity(severity))
+	}
+	if field != "" {
+		result.Field = &fn.Field{Path: field}
+	}
+	s.results = append(s.results, result)
+	return starlark.None, nil
+}
+
+// toObject converts a dict back to a KubeObject, merging it into the yaml node
+// it was converted from, if any.
+func (s *script) toObject(v starlark.Value) (*fn.KubeObject, error) {
+	dict, ok := v.(*starlark.Dict)
+	if !ok {
+		return nil, fmt.Errorf("expected a dict, got %s", v.Type())
+	}
+	node, err := fromStarlark(dict, s.nodes[dict])
+	if err != nil {
+		return nil, err
+	}
+	return fromMapVariant(internal.NewMap(node))
+}
+
+func (s *script) toObjects(list *starlark.List) (fn.KubeObjects, error) {
+	objs := fn.KubeObjects{}
+	for i := 0; i < list.Len(); i++ {
+		obj, err := s.toObject(list.Index(i))
+		if err != nil {
+			return nil, fmt.Errorf("[%d]: %w", i, err)
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// writeBack replaces the origin, the target, the items and the outputs of rctx
+// with the values the script left.
+func (s *script) writeBack(rctx *fn.ResourceContext) error {
+	outputs, err := s.toObjects(s.outputs)
+	if err != nil {
+		return fmt.Errorf("invalid outputs%w", err)
+	}
+	rctx.Outputs = outputs
+	if rctx.Input == nil {
+		return nil
+	}
+	if rctx.Input.Origin != nil {
+		if rctx.Input.Origin, err = s.toObject(s.origin); err != nil {
+			return fmt.Errorf("invalid origin: %w", err)
+		}
+	}
+	if rctx.Input.Target != nil {
+		if rctx.Input.Target, err = s.toObject(s.target); err != nil {
+			return fmt.Errorf("invalid target: %w", err)
+		}
+	}
+	items, err := s.toObjects(s.items)
+	if err != nil {
+		return fmt.Errorf("invalid items%w", err)
+	}
+	rctx.Input.Items = items
+	return nil
+}
+
+// scriptResult converts a script error to a Result, with the Starlark
+// backtrace for errors raised while executing the script.
+func scriptResult(err error) *fn.Result {
+	if evalErr, ok := err.(*starlark.EvalError); ok {
+		return fn.GeneralResult(evalErr.Backtrace(), fn.Error)
+	}
+	return fn.ErrorResult(err)
+}
@@ -0,0 +1,90 @@
+package starlark
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"go.starlark.net/resolve"
+)
+
+const input = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+    spec:
+      mtu: 9000 # jumbo frames
+  items:
+  - apiVersion: v1
+    kind: Interface
+    metadata:
+      name: eth0
+    spec:
+      mtu: 1500
+`
+
+func TestProcessDialect(t *testing.T) {
+	rctx, err := fn.ParseResourceContext([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// top-level control, while, global reassignment, recursion and sets
+	p := New(`
+def depth(n):
+    return 0 if n == 0 else 1 + depth(n - 1)
+
+count = 0
+for item in items:
+    if item["kind"] == "Interface":
+        item["spec"]["mtu"] = origin["spec"]["mtu"]
+        count += 1
+while count < depth(3):
+    count += 1
+kinds = set([item["kind"] for item in items])
+add_output({"apiVersion": "v1", "kind": "ConfigMap", "metadata": {"name": origin["metadata"]["name"]}, "data": {"count": str(count), "kinds": ",".join(sorted(kinds))}})
+`)
+	if ok, err := p.Process(rctx); !ok || err != nil {
+		t.Fatalf("unexpected failure %v: %v", err, rctx.Results)
+	}
+	if mtu, _, _ := rctx.Input.Items[0].NestedInt64("spec", "mtu"); mtu != 9000 {
+		t.Errorf("expected the mtu of the origin, got %d", mtu)
+	}
+	if len(rctx.Outputs) != 1 {
+		t.Fatalf("expected 1 output, got %d", len(rctx.Outputs))
+	}
+	data, _, _ := rctx.Outputs[0].NestedStringMap("data")
+	if data["count"] != "3" || data["kinds"] != "Interface" {
+		t.Errorf("unexpected output data %v", data)
+	}
+	if comment, _, _ := rctx.Input.Origin.LineComment("spec", "mtu"); comment != "# jumbo frames" {
+		t.Errorf("expected the comment of the origin to be kept, got %q", comment)
+	}
+
+	if resolve.AllowSet || resolve.AllowRecursion || resolve.AllowGlobalReassign {
+		t.Error("expected the process-wide resolve flags to be left untouched")
+	}
+}
+
+func TestProcessScriptError(t *testing.T) {
+	rctx, err := fn.ParseResourceContext([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Processor{Source: "result(\"checked\")\nfail(\"bad mtu\")\n", Name: "check.star"}
+	if ok, err := p.Process(rctx); ok || err == nil {
+		t.Fatal("expected the script to fail")
+	}
+	if len(rctx.Results) != 2 {
+		t.Fatalf("expected the script result and its error, got %v", rctx.Results)
+	}
+	if rctx.Results[0].Message != "checked" || rctx.Results[0].Severity != fn.Info {
+		t.Errorf("unexpected script result %v", rctx.Results[0])
+	}
+	if msg := rctx.Results[1].Message; rctx.Results[1].Severity != fn.Error || !strings.Contains(msg, "check.star:2") || !strings.Contains(msg, "bad mtu") {
+		t.Errorf("expected the error with its position, got %q", rctx.Results[1].Message)
+	}
+}
@@ -0,0 +1,157 @@
+package fn
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncfloat64"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// InstrumentationName is the name of the tracer and meter used by Run.
+	InstrumentationName = "github.com/yndd/app-functions-sdk/go/fn"
+
+	// span and stage names recorded by Run.
+	SpanRun       = "fn.Run"
+	StageParse    = "parse"
+	StageProcess  = "process"
+	StageToYAML   = "toYAML"
+	stageKey      = attribute.Key("fn.stage")
+	severityKey   = attribute.Key("fn.result.severity")
+	originAPIKey  = attribute.Key("fn.origin.apiVersion")
+	originKindKey = attribute.Key("fn.origin.kind")
+)
+
+// WithTracerProvider records spans for the run and each of its stages with tp.
+// By default no spans are recorded.
+func WithTracerProvider(tp trace.TracerProvider) RunOption {
+	return func(o *runOptions) {
+		o.tracerProvider = tp
+	}
+}
+
+// WithMeterProvider records run metrics with mp. By default no metrics are
+// recorded.
+func WithMeterProvider(mp metric.MeterProvider) RunOption {
+	return func(o *runOptions) {
+		o.meterProvider = mp
+	}
+}
+
+// StartSpan starts a span for a stage of a processor, e.g. one step of a
+// multi-stage transform. The span is a child of the process span created by Run
+// and uses its TracerProvider; without one it is a no-op.
+func StartSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	tp := trace.SpanFromContext(ctx).TracerProvider()
+	return tp.Tracer(InstrumentationName).Start(ctx, name, trace.WithAttributes(stageKey.String(name)))
+}
+
+// telemetry holds the tracer and instruments of a single run.
+type telemetry struct {
+	tracer        trace.Tracer
+	runDuration   syncfloat64.Histogram
+	stageDuration syncfloat64.Histogram
+	outputs       syncint64.Counter
+	results       syncint64.Counter
+}
+
+func newTelemetry(o *runOptions) *telemetry {
+	tp := o.tracerProvider
+	if tp == nil {
+		tp = trace.NewNoopTracerProvider()
+	}
+	mp := o.meterProvider
+	if mp == nil {
+		mp = metric.NewNoopMeterProvider()
+	}
+	meter := mp.Meter(InstrumentationName)
+	noop := metric.NewNoopMeter()
+
+	t := &telemetry{tracer: tp.Tracer(InstrumentationName)}
+	var err error
+	if t.runDuration, err = meter.SyncFloat64().Histogram("fn.run.duration",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("duration of a function run")); err != nil {
+		otel.Handle(err)
+		t.runDuration, _ = noop.SyncFloat64().Histogram("fn.run.duration")
+	}
+	if t.stageDuration, err = meter.SyncFloat64().Histogram("fn.stage.duration",
+		instrument.WithUnit(unit.Milliseconds),
+		instrument.WithDescription("duration of a stage of a function run")); err != nil {
+		otel.Handle(err)
+		t.stageDuration, _ = noop.SyncFloat64().Histogram("fn.stage.duration")
+	}
+	if t.outputs, err = meter.SyncInt64().Counter("fn.outputs",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("number of outputs rendered by a function")); err != nil {
+		otel.Handle(err)
+		t.outputs, _ = noop.SyncInt64().Counter("fn.outputs")
+	}
+	if t.results, err = meter.SyncInt64().Counter("fn.results",
+		instrument.WithUnit(unit.Dimensionless),
+		instrument.WithDescription("number of results reported by a function, by severity")); err != nil {
+		otel.Handle(err)
+		t.results, _ = noop.SyncInt64().Counter("fn.results")
+	}
+	return t
+}
+
+// startRun starts the root span of a run. The returned function ends the span
+// and records the run duration.
+func (t *telemetry) startRun(ctx context.Context) (context.Context, func(rctx *ResourceContext, err error)) {
+	start := time.Now()
+	ctx, span := t.tracer.Start(ctx, SpanRun)
+	return ctx, func(rctx *ResourceContext, err error) {
+		var attrs []attribute.KeyValue
+		if rctx != nil && rctx.Input != nil && rctx.Input.Origin != nil {
+			attrs = append(attrs,
+				originAPIKey.String(rctx.Input.Origin.GetAPIVersion()),
+				originKindKey.String(rctx.Input.Origin.GetKind()))
+		}
+		span.SetAttributes(attrs...)
+		endSpan(span, err)
+		t.runDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), attrs...)
+	}
+}
+
+// startStage starts the span of a stage of a run. The returned function ends
+// the span and records the stage duration.
+func (t *telemetry) startStage(ctx context.Context, stage string) (context.Context, func(err error)) {
+	start := time.Now()
+	ctx, span := t.tracer.Start(ctx, stage, trace.WithAttributes(stageKey.String(stage)))
+	return ctx, func(err error) {
+		endSpan(span, err)
+		t.stageDuration.Record(ctx, float64(time.Since(start))/float64(time.Millisecond), stageKey.String(stage))
+	}
+}
+
+// recordResponse records the outputs and results of the response.
+func (t *telemetry) recordResponse(ctx context.Context, rctx *ResourceContext) {
+	if rctx == nil {
+		return
+	}
+	t.outputs.Add(ctx, int64(len(rctx.Outputs)))
+	for _, result := range rctx.Results {
+		severity := result.Severity
+		if severity == "" {
+			severity = Info
+		}
+		t.results.Add(ctx, 1, severityKey.String(string(severity)))
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
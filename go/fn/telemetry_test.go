@@ -0,0 +1,184 @@
+package fn_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/oteltest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+const telemetryInput = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+`
+
+// addConfigMap returns a processor that adds a ConfigMap output and fails with
+// err, if any, which it also reports in the Results.
+func addConfigMap(err error) fn.ContextProcessor {
+	return fn.ContextProcessorFunc(func(ctx context.Context, rctx *fn.ResourceContext) (bool, error) {
+		output, parseErr := fn.ParseKubeObject([]byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: cm\n"))
+		if parseErr != nil {
+			return false, parseErr
+		}
+		rctx.Outputs = append(rctx.Outputs, output)
+		rctx.LogResult(err)
+		return err == nil, err
+	})
+}
+
+func spanByName(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name == name {
+			return span
+		}
+	}
+	t.Fatalf("no span %q in %v", name, spans)
+	return tracetest.SpanStub{}
+}
+
+func hasAttribute(attrs []attribute.KeyValue, kv attribute.KeyValue) bool {
+	for _, attr := range attrs {
+		if attr == kv {
+			return true
+		}
+	}
+	return false
+}
+
+func TestRunSpans(t *testing.T) {
+	tests := map[string]struct {
+		err        error
+		wantStatus codes.Code
+	}{
+		"success": {wantStatus: codes.Unset},
+		"failure": {err: errors.New("transform failed"), wantStatus: codes.Error},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := oteltest.New()
+			_, err := fn.RunContext(context.Background(), addConfigMap(tc.err), []byte(telemetryInput), rec.RunOptions()...)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("unexpected error %v", err)
+			}
+
+			wantNames := []string{fn.StageParse, fn.StageProcess, fn.StageToYAML, fn.SpanRun}
+			if names := rec.SpanNames(); !reflect.DeepEqual(names, wantNames) {
+				t.Errorf("expected spans %v, got %v", wantNames, names)
+			}
+			spans := rec.Spans()
+			run := spanByName(t, spans, fn.SpanRun)
+			for _, stage := range wantNames[:3] {
+				span := spanByName(t, spans, stage)
+				if span.Parent.SpanID() != run.SpanContext.SpanID() {
+					t.Errorf("expected span %s to be a child of %s", stage, fn.SpanRun)
+				}
+				if !hasAttribute(span.Attributes, attribute.String("fn.stage", stage)) {
+					t.Errorf("expected span %s to have its stage attribute, got %v", stage, span.Attributes)
+				}
+			}
+			for _, kv := range []attribute.KeyValue{
+				attribute.String("fn.origin.apiVersion", "app.yndd.io/v1alpha1"),
+				attribute.String("fn.origin.kind", "App"),
+			} {
+				if !hasAttribute(run.Attributes, kv) {
+					t.Errorf("expected span %s to have attribute %v, got %v", fn.SpanRun, kv, run.Attributes)
+				}
+			}
+			for _, name := range []string{fn.SpanRun, fn.StageProcess} {
+				span := spanByName(t, spans, name)
+				if span.Status.Code != tc.wantStatus {
+					t.Errorf("expected span %s to have status %v, got %v", name, tc.wantStatus, span.Status)
+				}
+				if tc.err != nil && span.Status.Description != tc.err.Error() {
+					t.Errorf("expected span %s to describe the error, got %q", name, span.Status.Description)
+				}
+			}
+			for _, name := range []string{fn.StageParse, fn.StageToYAML} {
+				if span := spanByName(t, spans, name); span.Status.Code != codes.Unset {
+					t.Errorf("expected span %s to succeed, got %v", name, span.Status)
+				}
+			}
+		})
+	}
+}
+
+func TestRunSpansParseError(t *testing.T) {
+	rec := oteltest.New()
+	if _, err := fn.RunContext(context.Background(), addConfigMap(nil), []byte("kind: Unknown\n"), rec.RunOptions()...); err == nil {
+		t.Fatal("expected a parse error")
+	}
+	wantNames := []string{fn.StageParse, fn.SpanRun}
+	if names := rec.SpanNames(); !reflect.DeepEqual(names, wantNames) {
+		t.Errorf("expected spans %v, got %v", wantNames, names)
+	}
+	for _, name := range wantNames {
+		if span := spanByName(t, rec.Spans(), name); span.Status.Code != codes.Error {
+			t.Errorf("expected span %s to have status %v, got %v", name, codes.Error, span.Status)
+		}
+	}
+}
+
+func TestRunMetrics(t *testing.T) {
+	rec := oteltest.New()
+	if _, err := fn.RunContext(context.Background(), addConfigMap(errors.New("transform failed")), []byte(telemetryInput), rec.RunOptions()...); err == nil {
+		t.Fatal("expected an error")
+	}
+	rm, err := rec.Metrics(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	metrics := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		if sm.Scope.Name != fn.InstrumentationName {
+			continue
+		}
+		for _, m := range sm.Metrics {
+			metrics[m.Name] = m.Data
+		}
+	}
+
+	outputs, ok := metrics["fn.outputs"].(metricdata.Sum[int64])
+	if !ok || len(outputs.DataPoints) != 1 || outputs.DataPoints[0].Value != 1 {
+		t.Errorf("expected 1 output, got %+v", metrics["fn.outputs"])
+	}
+	results, ok := metrics["fn.results"].(metricdata.Sum[int64])
+	if !ok || len(results.DataPoints) != 1 || results.DataPoints[0].Value != 1 {
+		t.Fatalf("expected 1 result, got %+v", metrics["fn.results"])
+	}
+	if severity, _ := results.DataPoints[0].Attributes.Value("fn.result.severity"); severity.AsString() != string(fn.Error) {
+		t.Errorf("expected an error result, got severity %q", severity.AsString())
+	}
+	run, ok := metrics["fn.run.duration"].(metricdata.Histogram)
+	if !ok || len(run.DataPoints) != 1 || run.DataPoints[0].Count != 1 {
+		t.Fatalf("expected 1 run duration, got %+v", metrics["fn.run.duration"])
+	}
+	if kind, _ := run.DataPoints[0].Attributes.Value("fn.origin.kind"); kind.AsString() != "App" {
+		t.Errorf("expected the run duration to have the origin kind, got %q", kind.AsString())
+	}
+	stages, ok := metrics["fn.stage.duration"].(metricdata.Histogram)
+	if !ok {
+		t.Fatalf("expected stage durations, got %+v", metrics["fn.stage.duration"])
+	}
+	got := map[string]uint64{}
+	for _, dp := range stages.DataPoints {
+		stage, _ := dp.Attributes.Value("fn.stage")
+		got[stage.AsString()] = dp.Count
+	}
+	want := map[string]uint64{fn.StageParse: 1, fn.StageProcess: 1, fn.StageToYAML: 1}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected stage durations %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,249 @@
+// Package wasm runs functions compiled to WebAssembly, e.g. with
+// GOOS=wasip1 GOARCH=wasm, in a sandbox within the current process. The
+// ResourceContext is passed to the WASI module over stdin and read back from
+// its stdout, as for a function binary. The module has no access to the file
+// system or the network, and its memory and run time are bounded.
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+	"github.com/tetratelabs/wazero/sys"
+	"github.com/yndd/app-functions-sdk/go/fn"
+	"github.com/yndd/app-functions-sdk/go/fn/client"
+	"github.com/yndd/app-functions-sdk/go/fn/internal"
+)
+
+const (
+	// DefaultMemoryLimit bounds the memory of a module when no limit is
+	// configured.
+	DefaultMemoryLimit = 512 << 20
+
+	// pageSize is the size of a WebAssembly memory page.
+	pageSize = 64 << 10
+)
+
+// Option configures a Runner.
+type Option func(*Runner)
+
+// WithArgs sets the arguments the module is run with, after the module name.
+func WithArgs(args ...string) Option {
+	return func(r *Runner) {
+		r.args = args
+	}
+}
+
+// WithEnv sets the environment variables of the module. The module doesn't see
+// the environment of the current process.
+func WithEnv(env map[string]string) Option {
+	return func(r *Runner) {
+		r.env = env
+	}
+}
+
+// WithTimeout bounds the run time of the module. It defaults to
+// client.DefaultTimeout; zero disables the timeout.
+func WithTimeout(d time.Duration) Option {
+	return func(r *Runner) {
+		r.timeout = d
+	}
+}
+
+// WithMaxOutputSize bounds the ResourceContext the module returns. It defaults
+// to client.DefaultMaxOutputSize.
+func WithMaxOutputSize(n int64) Option {
+	return func(r *Runner) {
+		r.maxOutputSize = n
+	}
+}
+
+// WithMemoryLimit bounds the memory of the module, rounded down to whole
+// pages. It defaults to DefaultMemoryLimit.
+func WithMemoryLimit(n uint64) Option {
+	return func(r *Runner) {
+		r.memoryLimit = n
+	}
+}
+
+// WithStderr streams the logs of the module to w in addition to capturing
+// them.
+func WithStderr(w io.Writer) Option {
+	return func(r *Runner) {
+		r.stderr = w
+	}
+}
+
+// WithCompilationCache shares the compiled code of modules through cache, so
+// a module that was compiled before, e.g. by a previous Runner, is not compiled
+// again.
+func WithCompilationCache(cache wazero.CompilationCache) Option {
+	return func(r *Runner) {
+		r.cache = cache
+	}
+}
+
+// Runner runs a compiled WebAssembly function. It is safe for concurrent use;
+// every run instantiates the module anew.
+type Runner struct {
+	name          string
+	args          []string
+	env           map[string]string
+	timeout       time.Duration
+	maxOutputSize int64
+	memoryLimit   uint64
+	stderr        io.Writer
+	cache         wazero.CompilationCache
+
+	runtime wazero.Runtime
+	module  wazero.CompiledModule
+	runs    uint64
+}
+
+// Load returns a Runner for the WebAssembly module in the file at path.
+func Load(ctx context.Context, path string, opts ...Option) (*Runner, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return New(ctx, path, b, opts...)
+}
+
+// New compiles the WebAssembly module binary and returns a Runner for it.
+// name identifies the module in errors. The Runner must be closed when it is
+// no longer used.
+func New(ctx context.Context, name string, binary []byte, opts ...Option) (*Runner, error) {
+	r := &Runner{
+		name:          name,
+		timeout:       client.DefaultTimeout,
+		maxOutputSize: client.DefaultMaxOutputSize,
+		memoryLimit:   DefaultMemoryLimit,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	pages := r.memoryLimit / pageSize
+	if pages == 0 || pages > 65536 {
+		return nil, fmt.Errorf("invalid memory limit %d, it must be between %d and 4GiB", r.memoryLimit, pageSize)
+	}
+	config := wazero.NewRuntimeConfig().
+		WithMemoryLimitPages(uint32(pages)).
+		WithCloseOnContextDone(true)
+	if r.cache != nil {
+		config = config.WithCompilationCache(r.cache)
+	}
+	r.runtime = wazero.NewRuntimeWithConfig(ctx, config)
+	if _, err := wasi_snapshot_preview1.Instantiate(ctx, r.runtime); err != nil {
+		r.runtime.Close(ctx)
+		return nil, fmt.Errorf("unable to instantiate WASI: %w", err)
+	}
+	module, err := r.runtime.CompileModule(ctx, binary)
+	if err != nil {
+		r.runtime.Close(ctx)
+		return nil, fmt.Errorf("unable to compile function %s: %w", name, err)
+	}
+	r.module = module
+	return r, nil
+}
+
+// Close releases the compiled module.
+func (r *Runner) Close(ctx context.Context) error {
+	return r.runtime.Close(ctx)
+}
+
+// Run runs the module on the ResourceContext in yaml in and returns the
+// ResourceContext it wrote to stdout, so a Runner can be used as an
+// fnrun.Runner.
+func (r *Runner) Run(ctx context.Context, in []byte) ([]byte, error) {
+	resp, err := r.RunBytes(ctx, in)
+	if resp == nil {
+		return nil, err
+	}
+	return resp.Raw, err
+}
+
+// RunBytes runs the module on the ResourceContext in yaml in. As for
+// client.Client.RunBytes, the Response is returned whenever the module ran,
+// along with a *client.ExitError, *client.TimeoutError,
+// *client.OutputLimitError or *client.ParseError if it failed. A module that
+// exceeds its memory limit fails with an ExitError.
+func (r *Runner) RunBytes(ctx context.Context, in []byte) (*client.Response, error) {
+	var cancel context.CancelFunc
+	if r.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	stdout := &internal.LimitWriter{Limit: r.maxOutputSize, Exceeded: cancel}
+	stderr := &internal.LimitWriter{Limit: client.DefaultMaxStderrSize}
+	config := wazero.NewModuleConfig().
+		WithName(fmt.Sprintf("%s#%d", r.name, atomic.AddUint64(&r.runs, 1))).
+		WithArgs(append([]string{r.name}, r.args...)...).
+		WithStdin(bytes.NewReader(in)).
+		WithStdout(stdout).
+		WithStderr(stderr).
+		WithSysWalltime().
+		WithSysNanotime().
+		WithSysNanosleep()
+	if r.stderr != nil {
+		config = config.WithStderr(io.MultiWriter(stderr, r.stderr))
+	}
+	for k, v := range r.env {
+		config = config.WithEnv(k, v)
+	}
+
+	start := time.Now()
+	module, err := r.runtime.InstantiateModule(ctx, r.module, config)
+	if module != nil {
+		module.Close(ctx)
+	}
+	resp := &client.Response{
+		Raw:      stdout.Bytes(),
+		Stderr:   stderr.Bytes(),
+		Duration: time.Since(start),
+	}
+	var exitErr *sys.ExitError
+	if errors.As(err, &exitErr) {
+		resp.ExitCode = int(exitErr.ExitCode())
+	} else if err != nil && ctx.Err() == nil {
+		return nil, fmt.Errorf("unable to run function %s: %w", r.name, err)
+	}
+
+	switch {
+	case stdout.Overflow():
+		return resp, &client.OutputLimitError{Path: r.name, Limit: r.maxOutputSize}
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return resp, &client.TimeoutError{Path: r.name, Timeout: r.timeout}
+	case ctx.Err() != nil:
+		return resp, ctx.Err()
+	}
+	if len(bytes.TrimSpace(resp.Raw)) > 0 {
+		rctx, err := fn.ParseResourceContext(resp.Raw)
+		if err != nil {
+			return resp, &client.ParseError{Path: r.name, Err: err}
+		}
+		resp.ResourceContext = rctx
+	}
+	if resp.ExitCode != 0 {
+		return resp, &client.ExitError{
+			Path:     r.name,
+			ExitCode: resp.ExitCode,
+			Stderr:   string(resp.Stderr),
+			Results:  resp.Results(),
+		}
+	}
+	if resp.ResourceContext == nil {
+		return resp, &client.ParseError{Path: r.name, Err: fmt.Errorf("the function returned no ResourceContext")}
+	}
+	return resp, nil
+}
@@ -0,0 +1,235 @@
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yndd/app-functions-sdk/go/fn/client"
+)
+
+const testOutput = `apiVersion: app.yndd.io/v1
+kind: ResourceContext
+input:
+  origin:
+    apiVersion: app.yndd.io/v1alpha1
+    kind: App
+    metadata:
+      name: app1
+results:
+- message: invalid origin
+  severity: error
+`
+
+// loop is the instructions of a test module that loops forever, see
+// testModule.
+var loop = []byte{0x03, 0x40, 0x0c, 0x00, 0x0b}
+
+// write writes the data of the module to fd.
+func write(fd byte) []byte {
+	// fd_write(fd, iovs=0, iovs_len=1, nwritten=8); drop
+	return []byte{0x41, fd, 0x41, 0x00, 0x41, 0x01, 0x41, 0x08, 0x10, 0x01, 0x1a}
+}
+
+// exit exits with code.
+func exit(code byte) []byte {
+	// proc_exit(code)
+	return []byte{0x41, code, 0x10, 0x00}
+}
+
+// testModule assembles a WASI module whose _start runs the given instructions.
+// The module holds data in its memory, for write.
+func testModule(data string, instructions ...[]byte) []byte {
+	section := func(id byte, content ...[]byte) []byte {
+		b := concat(content...)
+		return concat([]byte{id}, uleb(len(b)), b)
+	}
+	name := func(s string) []byte { return concat(uleb(len(s)), []byte(s)) }
+	body := concat([]byte{0x00}, concat(instructions...), []byte{0x0b})
+	// the iovec of the data, at offset 0, points to the data at offset 16
+	iovec := make([]byte, 8)
+	binary.LittleEndian.PutUint32(iovec, 16)
+	binary.LittleEndian.PutUint32(iovec[4:], uint32(len(data)))
+
+	return concat(
+		[]byte("\x00asm\x01\x00\x00\x00"),
+		// types: (i32), (), (i32 i32 i32 i32) -> i32
+		section(0x01, []byte{0x03, 0x60, 0x01, 0x7f, 0x00, 0x60, 0x00, 0x00, 0x60, 0x04, 0x7f, 0x7f, 0x7f, 0x7f, 0x01, 0x7f}),
+		section(0x02, []byte{0x02},
+			name("wasi_snapshot_preview1"), name("proc_exit"), []byte{0x00, 0x00},
+			name("wasi_snapshot_preview1"), name("fd_write"), []byte{0x00, 0x02}),
+		section(0x03, []byte{0x01, 0x01}),
+		section(0x05, []byte{0x01, 0x00, 0x01}),
+		section(0x07, []byte{0x02}, name("_start"), []byte{0x00, 0x02}, name("memory"), []byte{0x02, 0x00}),
+		section(0x0a, []byte{0x01}, uleb(len(body)), body),
+		section(0x0b, []byte{0x02},
+			[]byte{0x00, 0x41, 0x00, 0x0b}, uleb(len(iovec)), iovec,
+			[]byte{0x00, 0x41, 0x10, 0x0b}, uleb(len(data)), []byte(data)),
+	)
+}
+
+func concat(parts ...[]byte) []byte {
+	var b []byte
+	for _, part := range parts {
+		b = append(b, part...)
+	}
+	return b
+}
+
+func uleb(n int) []byte {
+	var b []byte
+	for {
+		c := byte(n & 0x7f)
+		n >>= 7
+		if n == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func TestRunBytes(t *testing.T) {
+	tests := map[string]struct {
+		data         string
+		instructions [][]byte
+		opts         []Option
+		check        func(t *testing.T, resp *client.Response, err error)
+	}{
+		"success": {
+			data:         testOutput,
+			instructions: [][]byte{write(1)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if resp.ResourceContext == nil || len(resp.Results()) != 1 {
+					t.Errorf("expected the ResourceContext, got\n%s", resp.Raw)
+				}
+			},
+		},
+		"exit with results": {
+			data:         testOutput,
+			instructions: [][]byte{write(1), exit(1)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var exitErr *client.ExitError
+				if !errors.As(err, &exitErr) || exitErr.ExitCode != 1 || len(exitErr.Results) != 1 {
+					t.Fatalf("expected an ExitError with code 1 and the Results, got %v", err)
+				}
+				if !strings.HasSuffix(err.Error(), "exited with code 1: [error]: invalid origin") {
+					t.Errorf("unexpected error %q", err.Error())
+				}
+			},
+		},
+		"exit with logs": {
+			data:         "out of memory\n",
+			instructions: [][]byte{write(2), exit(3)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var exitErr *client.ExitError
+				if !errors.As(err, &exitErr) || exitErr.ExitCode != 3 || resp.ExitCode != 3 {
+					t.Fatalf("expected an ExitError with code 3, got %v", err)
+				}
+				if exitErr.Stderr != "out of memory\n" || !strings.HasSuffix(err.Error(), ": out of memory") {
+					t.Errorf("expected the error to tell the logs, got %q", err.Error())
+				}
+			},
+		},
+		"exit 0 without output": {
+			instructions: [][]byte{exit(0)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var parseErr *client.ParseError
+				if !errors.As(err, &parseErr) || !strings.Contains(err.Error(), "returned no ResourceContext") {
+					t.Fatalf("expected a ParseError, got %v", err)
+				}
+			},
+		},
+		"invalid output": {
+			data:         "- not\n- a ResourceContext\n",
+			instructions: [][]byte{write(1)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var parseErr *client.ParseError
+				if !errors.As(err, &parseErr) {
+					t.Fatalf("expected a ParseError, got %v", err)
+				}
+			},
+		},
+		"output limit": {
+			data:         testOutput,
+			instructions: [][]byte{write(1)},
+			opts:         []Option{WithMaxOutputSize(16)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var limitErr *client.OutputLimitError
+				if !errors.As(err, &limitErr) || limitErr.Limit != 16 {
+					t.Fatalf("expected an OutputLimitError, got %v", err)
+				}
+			},
+		},
+		"timeout": {
+			instructions: [][]byte{loop},
+			opts:         []Option{WithTimeout(50 * time.Millisecond)},
+			check: func(t *testing.T, resp *client.Response, err error) {
+				var timeoutErr *client.TimeoutError
+				if !errors.As(err, &timeoutErr) || timeoutErr.Timeout != 50*time.Millisecond {
+					t.Fatalf("expected a TimeoutError, got %v", err)
+				}
+			},
+		},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			r, err := New(ctx, "test.wasm", testModule(tc.data, tc.instructions...), tc.opts...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close(ctx)
+			resp, err := r.RunBytes(ctx, nil)
+			if resp == nil {
+				t.Fatalf("expected a Response, got error %v", err)
+			}
+			tc.check(t, resp, err)
+		})
+	}
+}
+
+func TestRunBytesCancel(t *testing.T) {
+	ctx := context.Background()
+	r, err := New(ctx, "test.wasm", testModule("", loop), WithTimeout(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close(ctx)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	time.AfterFunc(50*time.Millisecond, cancel)
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.RunBytes(runCtx, nil)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the run to be canceled, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the canceled module to be closed")
+	}
+
+	// the Runner is still usable after a canceled run
+	runCtx, cancel = context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if _, err := r.RunBytes(runCtx, nil); !errors.Is(runCtx.Err(), context.DeadlineExceeded) || err == nil {
+		t.Errorf("expected the module to run until the deadline, got %v", err)
+	}
+}
+
+func TestNewMemoryLimit(t *testing.T) {
+	for _, limit := range []uint64{0, pageSize - 1, 65537 * pageSize} {
+		if _, err := New(context.Background(), "test.wasm", testModule(""), WithMemoryLimit(limit)); err == nil || !strings.Contains(err.Error(), "invalid memory limit") {
+			t.Errorf("expected an invalid memory limit %d to be rejected, got %v", limit, err)
+		}
+	}
+}